@@ -4,61 +4,155 @@ import (
 	"context"
 	"fmt"
 	"github.com/google/shlex"
+	"github.com/pkg/errors"
 	"github.com/relvacode/pipe/console"
+	"github.com/relvacode/pipe/dsl"
 	"github.com/relvacode/pipe/tap"
 	"github.com/sirupsen/logrus"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
 )
 
-// StdinPipe emits a single os.Stdin object
+// StdinPipe emits a single os.Stdin object, or a frame for each part of stdin if Framing splits it.
+// If the pipeline is drained then stdin is read as if it has ended.
 type StdinPipe struct {
+	Framing Framing
 }
 
-func (StdinPipe) Go(ctx context.Context, stream Stream) error {
-	return stream.Write(nil, os.Stdin)
+func (p StdinPipe) Go(ctx context.Context, stream Stream) error {
+	drained := Drained(ctx)
+	if drained == nil {
+		return p.Framing.write(ctx, os.Stdin, stream)
+	}
+
+	r := drainReader{
+		file:    os.Stdin,
+		drained: drained,
+	}
+	go r.interrupt(ctx)
+	return p.Framing.write(ctx, r, stream)
+}
+
+// FilePipe emits each file in Paths, or a frame for each part of each file if Framing splits it
+type FilePipe struct {
+	Paths   []string
+	Framing Framing
+}
+
+func (p FilePipe) Go(ctx context.Context, stream Stream) error {
+	for _, path := range p.Paths {
+		if drained(ctx) {
+			return nil
+		}
+		f, err := tap.OpenFile(path)
+		if err != nil {
+			return errors.Wrap(err, "input")
+		}
+		err = p.Framing.write(ctx, f, stream)
+		if p.Framing.Framed() {
+			_ = f.Close()
+		}
+		if err != nil {
+			return errors.Wrapf(err, "input %s", path)
+		}
+	}
+	return nil
+}
+
+// EmptyPipe emits a single empty frame without reading anything
+type EmptyPipe struct {
+}
+
+func (EmptyPipe) Go(ctx context.Context, stream Stream) error {
+	return stream.Write(nil, nil)
 }
 
-// EchoPipe echos all output to stdout
+// EchoPipe writes all objects to Writer
 type EchoPipe struct {
 	Writer io.Writer
+	// Output is how each object is written, the default is Raw
+	Output Output
+	// Separator is written between each object when using Raw, or after each object when using NDJSON.
+	// The default is a new line.
+	Separator string
 }
 
 func (p *EchoPipe) Go(ctx context.Context, stream Stream) error {
+	e, err := newEncoder(p.Output, p.Separator)
+	if err != nil {
+		return err
+	}
 	for i := 0; ; i++ {
 		f, err := stream.Read(nil)
+		if err == io.EOF {
+			return e.end(p.Writer, i)
+		}
 		if err != nil {
 			return err
 		}
 
-		if i > 0 {
-			fmt.Print("\n")
-		}
-
-		switch o := f.Object.(type) {
-		case io.Reader:
-			io.Copy(p.Writer, o)
-			tap.Close(o)
-		default:
-			fmt.Fprint(p.Writer, f.Object)
+		err = e.encode(p.Writer, i, f.Object)
+		if err != nil {
+			return err
 		}
-
 	}
 }
 
 func NewExecPkg(name string) Pkg {
 	return Pkg{
-		Name: name,
+		Name:        name,
+		Description: fmt.Sprintf("Run %s on the system for every input, writing its output", name),
+		Output:      []Kind{Reader},
 		Constructor: func(console *console.Command) Pipe {
 			return &ExecPipe{
 				name: name,
-				args: console.Any().Default("").Template(),
+				args: console.Any().Default("").Describe("The arguments to run the program with").Template(),
 			}
 		},
 	}
 }
 
+// NewAliasPkg creates a pipe named name that runs script in its place, using the pipes of reg.
+// Each instance of the alias creates new instances of the pipes in script.
+func NewAliasPkg(name, script string, reg *Registry) (Pkg, error) {
+	commands, err := dsl.Parse(strings.NewReader(script))
+	if err != nil {
+		return Pkg{}, err
+	}
+	if _, err = build(commands, reg); err != nil {
+		return Pkg{}, err
+	}
+	var stateful bool
+	for _, c := range commands {
+		pkg, ok := reg.Lookup(c.Name())
+		stateful = stateful || ok && pkg.Stateful && c.Group() == nil && c.Branches() == nil
+	}
+	return Pkg{
+		Name:        name,
+		Description: fmt.Sprintf("Alias for %s", script),
+		Alias:       script,
+		Stateful:    stateful,
+		Constructor: func(*console.Command) Pipe {
+			modules, err := build(commands, reg)
+			if err != nil {
+				return errorPipe{err: err}
+			}
+			return SubPipe(modules)
+		},
+	}, nil
+}
+
+// errorPipe fails with its error
+type errorPipe struct {
+	err error
+}
+
+func (p errorPipe) Go(context.Context, Stream) error {
+	return p.err
+}
+
 // ExecPipe executes a args
 type ExecPipe struct {
 	name string
@@ -103,7 +197,7 @@ func (p ExecPipe) execFrame(ctx context.Context, f *DataFrame, stream Stream) er
 		return err
 	}
 
-	return cmd.Wait()
+	return errors.Wrap(cmd.Wait(), p.name)
 }
 
 func (p *ExecPipe) Go(ctx context.Context, stream Stream) error {
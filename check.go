@@ -0,0 +1,148 @@
+package pipe
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/dsl"
+	"os/exec"
+)
+
+// accepts returns true if a pipe reading input can read any of the kinds of object written as output
+func accepts(input, output []Kind) bool {
+	if len(input) == 0 || len(output) == 0 {
+		return true
+	}
+	for _, i := range input {
+		for _, o := range output {
+			if i == Any || o == Any || i == o {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// only returns true if kinds is exactly the given kind
+func only(kinds []Kind, k Kind) bool {
+	return len(kinds) == 1 && kinds[0] == k
+}
+
+// name returns the name of this pipe as it was written in its script
+func (r Runnable) name() string {
+	switch {
+	case r.source != nil && r.source.Name() != "":
+		return r.source.Name()
+	case r.source != nil:
+		return r.source.Text()
+	}
+	return fmt.Sprintf("%T", r.Pipe)
+}
+
+// warn annotates err as a warning about this pipe
+func (r Runnable) warn(err error) error {
+	if r.source == nil {
+		return err
+	}
+	err = r.source.Err(err)
+	if e, ok := dsl.AsError(err); ok {
+		e.Warning = true
+	}
+	return err
+}
+
+// unwrap returns the pipe run by a pipe created from modifiers
+func unwrap(p Pipe) Pipe {
+	switch x := p.(type) {
+	case *PolicyPipe:
+		return unwrap(x.Pipe)
+	case *ParallelPipe:
+		if len(x.Pipes) > 0 {
+			return unwrap(x.Pipes[0])
+		}
+	}
+	return p
+}
+
+// pipelines returns the sub-pipelines run by p,
+// and whether each sub-pipeline is given the input of p.
+func pipelines(p Pipe) ([][]Runnable, bool) {
+	switch x := unwrap(p).(type) {
+	case ForkPipe:
+		return [][]Runnable{x}, true
+	case SubPipe:
+		return [][]Runnable{x}, true
+	case *TeePipe:
+		var branches = make([][]Runnable, len(x.Branches))
+		for i, b := range x.Branches {
+			branches[i] = b.Pipes
+		}
+		return branches, true
+	case *MergePipe:
+		var branches = make([][]Runnable, len(x.Branches))
+		for i, b := range x.Branches {
+			branches[i] = b.Pipes
+		}
+		return branches, false
+	}
+	return nil, false
+}
+
+// Check looks for pairs of pipes that can never work together,
+// where a pipe never writes an object of a kind that the pipe after it can read,
+// and for programs to run on the system that aren't found in PATH.
+// Groups and the branches of tee and merge are checked too.
+// The kinds of each pipe come from the Input and Output of its package,
+// so pipes of unknown kind are never reported.
+//
+// The returned errors are warnings, the pipeline can still be run.
+func Check(modules []Runnable) []error {
+	return check(modules, nil)
+}
+
+// check checks modules where from, if not nil, is the pipe writing to the first module
+func check(modules []Runnable, from *Runnable) []error {
+	var warnings []error
+	for i := range modules {
+		r := modules[i]
+		if only(r.Output, Same) {
+			r.Output = nil
+			if from != nil {
+				r.Output = from.Output
+			}
+		}
+		if from != nil {
+			if err := mismatch(*from, r); err != nil {
+				warnings = append(warnings, r.warn(err))
+			}
+		}
+		if p, ok := unwrap(r.Pipe).(*ExecPipe); ok {
+			if _, err := exec.LookPath(p.name); err != nil {
+				warnings = append(warnings, r.warn(errors.Errorf("%s is not a native pipe or alias and was not found in PATH", p.name)))
+			}
+		}
+
+		stages, fed := pipelines(r.Pipe)
+		for _, stage := range stages {
+			if fed {
+				warnings = append(warnings, check(stage, from)...)
+			} else {
+				warnings = append(warnings, check(stage, nil)...)
+			}
+		}
+		from = &r
+	}
+	return warnings
+}
+
+// mismatch returns an error if to can never read what from writes
+func mismatch(from, to Runnable) error {
+	switch {
+	case only(to.Input, Nothing):
+		return nil
+	case only(from.Output, Nothing):
+		return errors.Errorf("%s never writes anything so %s is never given any input", from.name(), to.name())
+	case !accepts(to.Input, from.Output):
+		return errors.Errorf("%s reads %s but %s writes %s", to.name(), kinds(to.Input, " or "), from.name(), kinds(from.Output, " or "))
+	}
+	return nil
+}
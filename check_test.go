@@ -0,0 +1,126 @@
+package pipe
+
+import (
+	"github.com/relvacode/pipe/console"
+	"github.com/relvacode/pipe/dsl"
+	"strings"
+	"testing"
+)
+
+// kindRegistry returns a registry of pipes that read and write the given kinds
+func kindRegistry() *Registry {
+	var reg = NewRegistry()
+	for name, k := range map[string][2][]Kind{
+		"number": {nil, {Number}},
+		"text":   {{Reader, String}, {String}},
+		"source": {{Nothing}, {Reader}},
+		"sink":   {nil, {Nothing}},
+		"any":    {nil, nil},
+		"same":   {nil, {Same}},
+	} {
+		reg.Define(Pkg{
+			Name:   name,
+			Input:  k[0],
+			Output: k[1],
+			Constructor: func(*console.Command) Pipe {
+				return TestPipe{}
+			},
+		})
+	}
+	return reg
+}
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		Script string
+		Expect []string
+	}{
+		{Script: "source :: text :: text :: any"},
+		{Script: "number :: any :: text"},
+		{Script: "number :: source :: text"},
+		{Script: "text :: sink"},
+		{Script: "text :: ( text ) as x :: any"},
+		{
+			Script: "number :: text",
+			Expect: []string{"text reads reader or string but number writes number"},
+		},
+		{
+			Script: "sink :: any",
+			Expect: []string{"sink never writes anything so any is never given any input"},
+		},
+		{
+			Script: "number :: ( any :: text ) :: text",
+			Expect: []string{
+				"text reads reader or string but ( any :: text ) writes list",
+			},
+		},
+		{
+			Script: "text :: ( text ) :: text",
+			Expect: []string{"text reads reader or string but ( text ) writes list"},
+		},
+		{
+			Script: "number :: ( text ) :: any",
+			Expect: []string{"text reads reader or string but number writes number"},
+		},
+		{
+			Script: "number :: tee ( text ) as a ( any ) as b",
+			Expect: []string{"text reads reader or string but number writes number"},
+		},
+		{
+			Script: "number :: merge ( text ) as a ( number :: text ) as b",
+			Expect: []string{"text reads reader or string but number writes number"},
+		},
+		{
+			Script: "number :: same :: same :: text",
+			Expect: []string{"text reads reader or string but same writes number"},
+		},
+		{Script: "same :: text"},
+		{
+			Script: "text :: no-such-program-for-pipe -x with jobs=2",
+			Expect: []string{"no-such-program-for-pipe is not a native pipe or alias and was not found in PATH"},
+		},
+		{
+			Script: "number :: text with jobs=2 errors=skip",
+			Expect: []string{"text reads reader or string but number writes number"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Script, func(t *testing.T) {
+			modules, err := Parse(strings.NewReader(tc.Script), kindRegistry())
+			if err != nil {
+				t.Fatal(err)
+			}
+			warnings := Check(modules)
+			if len(warnings) != len(tc.Expect) {
+				t.Fatalf("expected %d warnings but got %v", len(tc.Expect), warnings)
+			}
+			for i, w := range warnings {
+				e, ok := dsl.AsError(w)
+				if !ok || !e.Warning {
+					t.Fatalf("expected an annotated warning but got %v", w)
+				}
+				if e.Err.Error() != tc.Expect[i] {
+					t.Fatalf("expected %q but got %q", tc.Expect[i], e.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheck_Position(t *testing.T) {
+	modules, err := Parse(strings.NewReader("number :: any :: number\ntext"), kindRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := Check(modules)
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning but got %v", warnings)
+	}
+	e, _ := dsl.AsError(warnings[0])
+	if e.Line != 2 || e.Column != 1 || e.Stage != "text" {
+		t.Fatalf("expected warning for text at line 2, column 1 but got %q at %s", e.Stage, e.Position)
+	}
+	if !strings.HasPrefix(e.Display(), "warning: ") {
+		t.Fatalf("expected a warning but got\n%s", e.Display())
+	}
+}
@@ -6,9 +6,19 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/relvacode/pipe"
+	"github.com/relvacode/pipe/dsl"
 	"github.com/relvacode/pipe/profile"
+	"github.com/relvacode/pipe/repl"
 	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
 import (
@@ -19,28 +29,78 @@ import (
 var Version string = "localbuild"
 
 var (
-	flagDebug = flag.Bool("debug", false, "Enable debug logging")
-	flagNoRc  = flag.Bool("norc", false, "Disable profile")
+	flagDebug   = flag.Bool("debug", false, "Enable debug logging")
+	flagNoRc    = flag.Bool("norc", false, "Disable profile and plugins")
+	flagStats   = flag.Bool("stats", false, "Print stream statistics for each pipe on exit")
+	flagCheck   = flag.Bool("check", false, "Check that each pipe can read what the pipe before it writes then quit")
+	flagExplain = flag.Bool("explain", false, "Print how each pipe in the script is resolved then quit")
+	flagREPL    = flag.Bool("i", false, "Build a pipeline interactively, starting with the script if given")
 
-	flagLibrary = flag.Bool("lib", false, "Get usage for all native modules then quit")
-	flagPackage = flag.String("pkg", "", "Get usage for a specific package then quit")
+	flagLines   = flag.Bool("lines", false, "Start with a frame for each line of input")
+	flagNDJSON  = flag.Bool("ndjson", false, "Start with a frame for each JSON value of input, one per line")
+	flagNullIn  = flag.Bool("null-input", false, "Start with a frame for each part of input separated by NUL, each a JSON value with -ndjson")
+	flagNoInput = flag.Bool("no-input", false, "Start with a single empty frame instead of reading stdin")
+	flagInput   paths
+
+	flagOutput  = flag.String("output", string(pipe.Raw), "Write each output as raw, json, ndjson, yaml or table")
+	flagNullOut = flag.Bool("null-output", false, "Separate raw or ndjson output with NUL instead of a new line")
+	flagNull    = flag.Bool("null", false, "Shorthand for -null-input and -null-output, cannot be used with -lines or -ndjson")
+
+	flagBuffer  = flag.Int("buffer", 0, "Number of frames buffered between each pipe")
+	flagMetrics = flag.String("metrics", "", "Serve Prometheus metrics for each pipe on this address")
+
+	flagLibrary = flag.Bool("lib", false, "List all native pipes then quit")
+	flagPackage = flag.String("pkg", "", "Get the full help for a specific pipe then quit")
 )
 
+func init() {
+	flag.Var(&flagInput, "input", "Start with a frame for each file, or each part of it with -lines, -ndjson or -null-input, instead of reading stdin. Can be given more than once")
+}
+
+// paths is a flag that can be given more than once
+type paths []string
+
+func (p *paths) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p *paths) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
 func Main() error {
 	if *flagDebug {
 		logrus.SetLevel(logrus.DebugLevel)
 		logrus.Debugf("pipe %s", Version)
 	}
 
+	pipe.DefaultBuffer = *flagBuffer
+
+	input, err := inputPipe()
+	if err != nil {
+		return err
+	}
+	output, err := outputPipe()
+	if err != nil {
+		return err
+	}
+
+	// Plugins and aliases from the profile are layered on top of the native pipes
+	reg := pipe.Lib.Layer()
 	if !*flagNoRc {
-		err := profile.Load()
+		err := profile.LoadPlugins(reg)
+		if err != nil {
+			return err
+		}
+		err = profile.Load(reg)
 		if err != nil {
 			return err
 		}
 	}
 
 	if *flagPackage != "" {
-		pkg, ok := pipe.Lib[*flagPackage]
+		pkg, ok := reg.Lookup(*flagPackage)
 		if !ok {
 			return errors.Errorf("help: no such package %q", *flagPackage)
 		}
@@ -49,9 +109,12 @@ func Main() error {
 	}
 
 	if *flagLibrary {
-		for _, pkg := range pipe.Lib.Sorted() {
-			fmt.Println(pipe.Help(pkg))
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, pkg := range reg.Sorted() {
+			fmt.Fprintln(w, pipe.Summary(pkg))
 		}
+		_ = w.Flush()
+		fmt.Println("\nUse pipe -pkg <name> for the full help of a pipe")
 		os.Exit(0)
 	}
 
@@ -62,32 +125,224 @@ func Main() error {
 		}
 	}()
 
-	r, err := pipe.ScriptReaderOf(flag.Arg(0))
+	var args = flag.Args()
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	tap.Global("args", args)
+
+	r, err := pipe.ScriptReaderOf(flag.Arg(0), args...)
 	if err != nil {
 		return err
 	}
 
-	modules, err := pipe.Parse(r, pipe.Lib)
+	if *flagREPL {
+		var scripts []string
+		if flag.NArg() > 0 {
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			scripts = append(scripts, string(b))
+		}
+		s := repl.NewSession()
+		s.Registry = reg
+		return repl.Run(s, os.Stdin, os.Stdout, scripts...)
+	}
+
+	if *flagExplain {
+		tree, err := pipe.Explain(r, reg)
+		if err != nil {
+			return err
+		}
+		fmt.Print(tree)
+		return nil
+	}
+
+	modules, err := pipe.Parse(r, reg)
 	if err != nil {
 		return err
 	}
 	_ = tap.Close(r)
 
-	var (
-		ctx = context.Background()
-		i   = &pipe.StdinPipe{}
-		o   = &pipe.EchoPipe{
-			Writer: os.Stdout,
+	warnings := pipe.Check(modules)
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, display(w))
+	}
+	if *flagCheck {
+		if len(warnings) > 0 {
+			return errors.Errorf("check: found %d problem(s)", len(warnings))
+		}
+		return nil
+	}
+
+	if *flagStats || *flagMetrics != "" {
+		for i := range modules {
+			modules[i].Stats = new(pipe.Stats)
+		}
+	}
+	if *flagStats {
+		defer printStats(modules)
+	}
+	if *flagMetrics != "" {
+		server, err := serveMetrics(*flagMetrics, modules)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx, drain := pipe.WithDrain(ctx)
+	go handleSignals(drain, cancel)
+
+	err = pipe.RunIO(ctx, input, modules, output).ErrorOrNil()
+	if err == nil && ctx.Err() != nil {
+		err = errors.Wrap(ctx.Err(), "interrupted")
+	}
+	return err
+}
+
+// outputPipe creates the pipe that writes the output of the pipeline to stdout
+func outputPipe() (*pipe.EchoPipe, error) {
+	var o = &pipe.EchoPipe{
+		Writer: os.Stdout,
+		Output: pipe.Output(*flagOutput),
+	}
+
+	var known bool
+	for _, x := range pipe.Outputs {
+		known = known || x == o.Output
+	}
+	if !known {
+		return nil, errors.Errorf("output: expected one of %v but got %q", pipe.Outputs, *flagOutput)
+	}
+
+	_, null, err := nullFraming()
+	if err != nil {
+		return nil, err
+	}
+	if null {
+		o.Separator = "\x00"
+	}
+	return o, nil
+}
+
+// nullFraming returns whether the input is split and the output separated by NUL
+func nullFraming() (input, output bool, err error) {
+	switch {
+	case *flagNull && (*flagLines || *flagNDJSON):
+		return false, false, errors.New("null: cannot be used with -lines or -ndjson, use -null-input or -null-output instead")
+	case *flagNullIn && *flagLines:
+		return false, false, errors.New("null-input: cannot be used with -lines")
+	}
+	return *flagNull || *flagNullIn, *flagNull || *flagNullOut, nil
+}
+
+// inputPipe creates the pipe that writes the first frames of the pipeline
+func inputPipe() (pipe.Pipe, error) {
+	null, _, err := nullFraming()
+	if err != nil {
+		return nil, err
+	}
+
+	var framing pipe.Framing
+	switch {
+	case null:
+		framing.Separator = "\x00"
+	case *flagLines, *flagNDJSON:
+		framing.Separator = "\n"
+	}
+	framing.JSON = *flagNDJSON
+
+	switch {
+	case *flagNoInput:
+		if *flagLines || *flagNDJSON || len(flagInput) > 0 {
+			return nil, errors.New("no-input: cannot be used with -lines, -ndjson or -input")
+		}
+		return pipe.EmptyPipe{}, nil
+	case len(flagInput) > 0:
+		return pipe.FilePipe{Paths: flagInput, Framing: framing}, nil
+	}
+	return pipe.StdinPipe{Framing: framing}, nil
+}
+
+// exitTimeout is how long to wait for a cancelled pipeline to stop before exiting anyway
+const exitTimeout = 5 * time.Second
+
+// handleSignals drains the pipeline on the first interrupt and cancels it on the second.
+// If the pipeline still hasn't stopped after another interrupt or exitTimeout then the program exits.
+func handleSignals(drain, cancel func()) {
+	var signals = make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	sig := <-signals
+	logrus.Warnf("%s: stopping sources and waiting for the pipeline to finish, signal again to stop immediately", sig)
+	drain()
+
+	sig = <-signals
+	logrus.Warnf("%s: stopping immediately", sig)
+	cancel()
+
+	select {
+	case <-signals:
+	case <-time.After(exitTimeout):
+	}
+	logrus.Error("pipeline did not stop, exiting")
+	err := tap.Exit()
+	if err != nil {
+		logrus.Error(err)
+	}
+	os.Exit(1)
+}
+
+// serveMetrics serves the metrics of each module on addr in the background
+func serveMetrics(addr string, modules []pipe.Runnable) (*http.Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "metrics")
+	}
+
+	var mux = http.NewServeMux()
+	mux.Handle("/metrics", pipe.MetricsHandler(modules))
+	var server = &http.Server{Handler: mux}
+	go func() {
+		err := server.Serve(l)
+		if err != http.ErrServerClosed {
+			logrus.Error(errors.Wrap(err, "metrics"))
 		}
-	)
+	}()
+	logrus.Debugf("serving metrics on http://%s/metrics", l.Addr())
+	return server, nil
+}
 
-	return pipe.RunIO(ctx, i, modules, o).ErrorOrNil()
+// printStats prints the stream statistics of each module to stderr
+func printStats(modules []pipe.Runnable) {
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tPIPE\tTAG\tREAD\tWRITE\tREAD BLOCKED\tWRITE BLOCKED")
+	for i, m := range modules {
+		fmt.Fprintf(w, "%d\t%T\t%s\t%d\t%d\t%s\t%s\n", i, m.Pipe, m.Tag, m.Stats.Read(), m.Stats.Written(), m.Stats.ReadBlocked(), m.Stats.WriteBlocked())
+	}
+	_ = w.Flush()
+}
+
+// display renders err with the line of the script that caused it, if known
+func display(err error) string {
+	if e, ok := dsl.AsError(err); ok {
+		return e.Display()
+	}
+	return err.Error()
 }
 
 func main() {
 	flag.Parse()
 
 	err := Main()
+	if _, ok := dsl.AsError(err); ok {
+		fmt.Fprintln(os.Stderr, display(err))
+		os.Exit(1)
+	}
 	if err != nil {
 		logrus.Fatal(err)
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/google/shlex"
 	"github.com/pkg/errors"
+	"io/ioutil"
 	"strings"
 )
 
@@ -14,15 +15,27 @@ type Usage interface {
 
 type flagOption struct {
 	*Option
+	c *Command
 }
 
 func (flagOption) String() string {
 	return ""
 }
 
+// Set sets the option, remembering the error so that it is not lost when the flag set reports it
+func (f flagOption) Set(input string) error {
+	err := f.Option.Set(input)
+	if err != nil {
+		f.c.err = err
+	}
+	return err
+}
+
 func NewCommand() *Command {
+	f := flag.NewFlagSet("", flag.ContinueOnError)
+	f.SetOutput(ioutil.Discard)
 	return &Command{
-		flag: flag.NewFlagSet("", flag.ContinueOnError),
+		flag: f,
 	}
 }
 
@@ -31,6 +44,7 @@ type Command struct {
 	o    *Option
 	flag *flag.FlagSet
 	args []*Option
+	err  error // the last error setting an option
 }
 
 // Usage returns the usage for this command.
@@ -61,7 +75,7 @@ func (c *Command) Set(input string) error {
 	}
 	err = c.flag.Parse(args)
 	if err != nil {
-		return err
+		return c.flagError(err)
 	}
 
 	for i, a := range c.args {
@@ -73,6 +87,34 @@ func (c *Command) Set(input string) error {
 	return err
 }
 
+// flagError converts an error from parsing flags into an OptionError
+func (c *Command) flagError(err error) error {
+	if c.err != nil {
+		return c.err
+	}
+	const undefined = "flag provided but not defined: "
+	if msg := err.Error(); strings.HasPrefix(msg, undefined) {
+		name := strings.TrimPrefix(msg, undefined)
+		return &OptionError{
+			Input: name,
+			Err:   errors.Errorf("unknown option %s", name),
+		}
+	}
+	return &OptionError{Err: err}
+}
+
+// Options returns all flags in name order followed by all arguments in the order they are given
+func (c *Command) Options() []*Option {
+	if c.o != nil {
+		return []*Option{c.o}
+	}
+	var options []*Option
+	c.flag.VisitAll(func(f *flag.Flag) {
+		options = append(options, f.Value.(*flagOption).Option)
+	})
+	return append(options, c.args...)
+}
+
 func (c *Command) checkAnySet() {
 	if c.o != nil {
 		panic(errors.New("cannot call Any() more than once"))
@@ -83,8 +125,9 @@ func (c *Command) Option(name string) *Option {
 	c.checkAnySet()
 	var o = &Option{
 		name: name,
+		flag: true,
 	}
-	c.flag.Var(&flagOption{o}, name, "")
+	c.flag.Var(&flagOption{Option: o, c: c}, name, "")
 	return o
 }
 
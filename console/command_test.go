@@ -15,3 +15,17 @@ func TestOptions_Set(t *testing.T) {
 		t.Fatalf("Wanted %q; got %q", w, *a)
 	}
 }
+
+func TestOptions_SetBool(t *testing.T) {
+	o := NewCommand()
+	b := o.Option("body").Default(false).Bool()
+	a := o.Arg(0).String()
+	err := o.Set("-body true http://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !*b || *a != "http://example.com" {
+		t.Fatalf("Wanted true and %q; got %t and %q", "http://example.com", *b, *a)
+	}
+}
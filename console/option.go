@@ -22,21 +22,97 @@ type oType struct {
 	SetDefault func(value reflect.Value)
 }
 
+// OptionError is an error setting an option from the input given by the user
+type OptionError struct {
+	// Option describes the option, such as -name for a flag or argument 0 for the first argument
+	Option string
+	// Input is the input given to the option, if any
+	Input string
+	Err   error
+}
+
+func (e *OptionError) Error() string {
+	if e.Option == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Option, e.Err)
+}
+
 // Options convert a string value provided by the user to pointer value described when a pipe is constructed.
 type Option struct {
 	name         string
+	flag         bool
+	help         string
+	values       []string // each input this option was set to
 	optionType   *oType
 	defaultValue *reflect.Value
 }
 
+// Describe sets the help text of this option
+func (o *Option) Describe(help string) *Option {
+	o.help = help
+	return o
+}
+
+// Description returns the help text of this option
+func (o *Option) Description() string {
+	return o.help
+}
+
+// Name returns the name of this option.
+// Arguments are named by their position, and the option of Any has no name.
+func (o *Option) Name() string {
+	return o.name
+}
+
+// Value returns the input this option was set to, or its default value if it was not set.
+// Options set more than once, such as a map, return each input separated by a space.
+func (o *Option) Value() string {
+	switch {
+	case len(o.values) > 0:
+		return strings.Join(o.values, " ")
+	case o.defaultValue != nil && o.defaultValue.IsValid():
+		return fmt.Sprint(o.defaultValue.Interface())
+	}
+	return ""
+}
+
+// IsFlag returns true if this option is given as a flag such as -name
+func (o *Option) IsFlag() bool {
+	return o.flag
+}
+
+// Label returns how this option is described to a human, such as option -name or argument 0
+func (o *Option) Label() string {
+	switch {
+	case o.flag:
+		return "option -" + o.name
+	case o.name != "":
+		return "argument " + o.name
+	}
+	return "arguments"
+}
+
 func (o *Option) Set(input string) error {
 	if input == "" {
 		if o.defaultValue == nil {
-			return errors.Errorf("option %v: missing required argument (%s not provided)", o.name, o.optionType.Name)
+			return &OptionError{
+				Option: o.Label(),
+				Err:    errors.Errorf("missing required %s", o.optionType.Name),
+			}
 		}
 		return nil
 	}
-	return o.optionType.Parse(input)
+	err := o.optionType.Parse(input)
+	if err != nil {
+		return &OptionError{
+			Option: o.Label(),
+			Input:  input,
+			Err:    err,
+		}
+	}
+	o.values = append(o.values, input)
+	return nil
 }
 
 func (o *Option) Usage() string {
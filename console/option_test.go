@@ -26,3 +26,59 @@ func TestOptionDefault(t *testing.T) {
 		}
 	})
 }
+
+func TestOptionError(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		var c = NewCommand()
+		c.Option("n").Default(1).Int()
+		err := c.Set("-n abc")
+		oe, ok := err.(*OptionError)
+		if !ok {
+			t.Fatalf("expected %T but got %T (%v)", oe, err, err)
+		}
+		if oe.Option != "option -n" || oe.Input != "abc" {
+			t.Fatalf("expected error for option -n with input abc but got %q with input %q", oe.Option, oe.Input)
+		}
+	})
+	t.Run("unknown", func(t *testing.T) {
+		err := NewCommand().Set("-x")
+		oe, ok := err.(*OptionError)
+		if !ok {
+			t.Fatalf("expected %T but got %T (%v)", oe, err, err)
+		}
+		if oe.Input != "-x" || oe.Error() != "unknown option -x" {
+			t.Fatalf("expected unknown option -x but got %q", oe)
+		}
+	})
+	t.Run("missing", func(t *testing.T) {
+		var c = NewCommand()
+		c.Arg(0).String()
+		err := c.Set("")
+		if err == nil || err.Error() != "argument 0: missing required string" {
+			t.Fatalf("expected missing argument 0 but got %v", err)
+		}
+	})
+}
+
+func TestOptionValue(t *testing.T) {
+	var c = NewCommand()
+	c.Option("n").Default(5).Int()
+	c.Option("header").Default(nil).Map()
+	c.Arg(0).String()
+
+	err := c.Set("-header a:b -header c:d x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expect = map[string]string{
+		"option -header": "a:b c:d",
+		"option -n":      "5",
+		"argument 0":     "x",
+	}
+	for _, o := range c.Options() {
+		if o.Value() != expect[o.Label()] {
+			t.Fatalf("expected %s to be %q but got %q", o.Label(), expect[o.Label()], o.Value())
+		}
+	}
+}
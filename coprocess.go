@@ -0,0 +1,222 @@
+package pipe
+
+import (
+	"bufio"
+	"context"
+	"github.com/google/shlex"
+	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/tap"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// coprocess wraps create so that each program it creates runs once for all of its input,
+// separating each input and output with delimiter
+func coprocess(create Constructor, delimiter string) Constructor {
+	return func() (Pipe, error) {
+		p, err := create()
+		if err != nil {
+			return nil, err
+		}
+		e, ok := p.(*ExecPipe)
+		if !ok {
+			return nil, errors.New("modifier persistent can only be used with programs that aren't native pipes or aliases")
+		}
+		return &CoprocessPipe{
+			name:      e.name,
+			args:      e.args,
+			Delimiter: delimiter,
+		}, nil
+	}
+}
+
+// frameQueue are the frames written to a program in the order they were written
+type frameQueue struct {
+	mtx    sync.Mutex
+	frames []*DataFrame
+}
+
+func (q *frameQueue) push(f *DataFrame) {
+	q.mtx.Lock()
+	q.frames = append(q.frames, f)
+	q.mtx.Unlock()
+}
+
+func (q *frameQueue) pop() (*DataFrame, bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if len(q.frames) == 0 {
+		return nil, false
+	}
+	f := q.frames[0]
+	q.frames = q.frames[1:]
+	return f, true
+}
+
+// len returns the number of frames that haven't been popped
+func (q *frameQueue) len() int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return len(q.frames)
+}
+
+// CoprocessPipe runs a program once for all of its input instead of once for every frame.
+// The content of each frame is written to the program's stdin followed by Delimiter,
+// and its stdout is split by Delimiter into a string for each part.
+//
+// Each part is written with the frame at the same position in the input,
+// so the program must write exactly one part for each part it reads, such as sed or tr.
+// Programs that filter their input, such as grep, or that write more than one part for an input are not supported
+// and fail once the number of parts written and read differ.
+// The program's arguments are rendered using the first frame.
+// Once the program exits no more frames are read.
+type CoprocessPipe struct {
+	name      string
+	args      *tap.Template
+	Delimiter string
+}
+
+// programInput is the stdin of a program, remembering if writing to it failed
+type programInput struct {
+	io.WriteCloser
+	err error
+}
+
+func (in *programInput) Write(b []byte) (int, error) {
+	n, err := in.WriteCloser.Write(b)
+	if err != nil {
+		in.err = err
+	}
+	return n, err
+}
+
+// send writes the content of f followed by the delimiter to in
+func (p *CoprocessPipe) send(in io.Writer, f *DataFrame) error {
+	r, err := tap.Reader(f.Object)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(in, r)
+	_ = tap.Close(r)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(in, p.Delimiter)
+	return err
+}
+
+// input writes f and every frame read after it to in, until the input ends or done is closed
+func (p *CoprocessPipe) input(in *programInput, f *DataFrame, frames *frameQueue, stream Stream, done <-chan struct{}) error {
+	for {
+		frames.push(f)
+		err := p.send(in, f)
+		if in.err != nil {
+			// The program has stopped reading its input
+			logrus.Debugf("%s: %v", p.name, in.err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		f, err = stream.Read(done)
+		if err == io.EOF || err == ErrIOCancelled {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// output writes each part of r with the frame it was produced from
+func (p *CoprocessPipe) output(r io.Reader, frames *frameQueue, stream Stream) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxPart)
+	s.Split(splitAt(p.Delimiter))
+
+	for s.Scan() {
+		f, ok := frames.pop()
+		if !ok {
+			return errors.Errorf("%s: wrote more parts than it was given, persistent programs must write one part for each input", p.name)
+		}
+		err := stream.With(f).Write(nil, s.Text())
+		if err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+func (p *CoprocessPipe) Go(ctx context.Context, stream Stream) error {
+	f, err := stream.Read(nil)
+	if err != nil {
+		return err
+	}
+
+	fa, err := p.args.Render(f.Context())
+	if err != nil {
+		return err
+	}
+	args, err := shlex.Split(fa)
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("exec %q persistently", args)
+
+	cmd := exec.CommandContext(ctx, p.name, args...)
+	cmd.Env = os.Environ()
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	in := &programInput{WriteCloser: stdin}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return errors.Wrap(err, p.name)
+	}
+
+	var (
+		frames = new(frameQueue)
+		done   = make(chan struct{})
+		outErr error
+	)
+	go func() {
+		defer close(done)
+		outErr = p.output(stdout, frames, stream)
+		if outErr != nil {
+			// Stop the program so that writing its input doesn't block
+			_ = cmd.Process.Kill()
+		}
+	}()
+
+	inErr := p.input(in, f, frames, stream, done)
+	if inErr != nil {
+		_ = cmd.Process.Kill()
+	}
+	_ = stdin.Close()
+	<-done
+	waitErr := cmd.Wait()
+
+	switch {
+	case inErr != nil:
+		return inErr
+	case outErr != nil:
+		return outErr
+	case waitErr != nil:
+		return errors.Wrap(waitErr, p.name)
+	}
+	// A program that stopped reading its input may not have written a part for everything it was given
+	if n := frames.len(); n > 0 && in.err == nil {
+		return errors.Errorf("%s: wrote fewer parts than it was given, %d inputs have no output, persistent programs must write one part for each input", p.name, n)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package pipe
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runCoprocess runs script over objects and returns the frames it writes
+func runCoprocess(t *testing.T, script string, objects ...interface{}) []*DataFrame {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found in PATH")
+	}
+	var result = new(collectPipe)
+	p, err := New(script, WithRegistry(renderRegistry), WithObjects(objects...), WithOutput(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Frames
+}
+
+func TestCoprocessPipe(t *testing.T) {
+	frames := runCoprocess(t, `render {{this}} as x :: sh -c 'while read l; do echo $$-$l; done' with persistent`, "a", "b", "c")
+	if len(frames) != 3 {
+		t.Fatalf("expected 3 frames but got %d", len(frames))
+	}
+
+	var pid string
+	for i, f := range frames {
+		s := f.Object.(string)
+		parts := strings.SplitN(s, "-", 2)
+		if i == 0 {
+			pid = parts[0]
+		}
+		if parts[0] != pid {
+			t.Fatalf("expected every line to be written by process %s but got %q", pid, s)
+		}
+		if x := f.Stack["x"]; parts[1] != x {
+			t.Fatalf("expected %q to be written with the input %v in its stack", s, x)
+		}
+	}
+}
+
+func TestCoprocessPipe_Delimiter(t *testing.T) {
+	var got []interface{}
+	for _, f := range runCoprocess(t, `tr a-z A-Z with persistent=\x00`, "a\nb", "c") {
+		got = append(got, f.Object)
+	}
+	if expect := []interface{}{"A\nB", "C"}; !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expected %q but got %q", expect, got)
+	}
+}
+
+func TestCoprocessPipe_Exit(t *testing.T) {
+	var objects = make([]interface{}, 10000)
+	for i := range objects {
+		objects[i] = strings.Repeat("x", 100)
+	}
+	frames := runCoprocess(t, `head -n 2 with persistent`, objects...)
+	if len(frames) != 2 {
+		t.Fatalf("expected the input to stop once the program exits after 2 frames but got %d", len(frames))
+	}
+}
+
+func TestCoprocessPipe_Filter(t *testing.T) {
+	if _, err := exec.LookPath("grep"); err != nil {
+		t.Skip("grep not found in PATH")
+	}
+	for _, script := range []string{
+		`grep a with persistent`,
+		`sh -c 'while read l; do echo $l; echo $l; done' with persistent`,
+	} {
+		p, err := New(script, WithRegistry(renderRegistry), WithObjects("a", "b", "a"), WithOutput(new(collectPipe)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "one part for each input") {
+			t.Fatalf("%s: expected an error when the output doesn't match the input but got %v", script, err)
+		}
+	}
+}
+
+func TestCoprocessPipe_Native(t *testing.T) {
+	_, err := New("render x with persistent", WithRegistry(renderRegistry))
+	if err == nil || !strings.Contains(err.Error(), "persistent") {
+		t.Fatalf("expected an error using persistent with a native pipe but got %v", err)
+	}
+}
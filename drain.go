@@ -0,0 +1,72 @@
+package pipe
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type drainKey struct{}
+
+// WithDrain returns a context that can be drained by calling drain.
+// Draining a pipeline stops its sources so that the rest of the pipeline can finish processing what has already been produced.
+func WithDrain(parent context.Context) (ctx context.Context, drain func()) {
+	var (
+		c    = make(chan struct{})
+		once sync.Once
+	)
+	return context.WithValue(parent, drainKey{}, (<-chan struct{})(c)), func() {
+		once.Do(func() {
+			close(c)
+		})
+	}
+}
+
+// Drained returns a channel that is closed when the pipeline running with this context is drained.
+// Pipes that produce objects without reading their input should stop and return nil when it is closed.
+// If the context cannot be drained then the returned channel is nil.
+func Drained(ctx context.Context) <-chan struct{} {
+	c, _ := ctx.Value(drainKey{}).(<-chan struct{})
+	return c
+}
+
+// drainReader reads from a file until the pipeline is drained.
+// The file isn't embedded so that copying from the reader always goes through Read.
+type drainReader struct {
+	file    *os.File
+	drained <-chan struct{}
+}
+
+func (r drainReader) Read(b []byte) (int, error) {
+	select {
+	case <-r.drained:
+		return 0, io.EOF
+	default:
+	}
+	n, err := r.file.Read(b)
+	if err != nil {
+		select {
+		case <-r.drained:
+			// Reading was interrupted by the drain
+			return n, io.EOF
+		default:
+		}
+	}
+	return n, err
+}
+
+func (r drainReader) Close() error {
+	return r.file.Close()
+}
+
+// interrupt stops any blocked reads on the file when the pipeline is drained.
+// Not all files support this, in which case the blocked read stops the next time it reads anything.
+func (r drainReader) interrupt(ctx context.Context) {
+	select {
+	case <-r.drained:
+		_ = r.file.SetReadDeadline(time.Now())
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,84 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// drainSourcePipe writes numbers until the pipeline is drained
+type drainSourcePipe struct {
+	drain func()
+	after int
+}
+
+func (p drainSourcePipe) Go(ctx context.Context, stream Stream) error {
+	for i := 0; ; i++ {
+		if i == p.after {
+			p.drain()
+		}
+		select {
+		case <-Drained(ctx):
+			return nil
+		default:
+		}
+		err := stream.Write(nil, i)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestWithDrain(t *testing.T) {
+	var (
+		ctx, drain = WithDrain(context.Background())
+		result     = new(collectPipe)
+	)
+	err := Run(ctx, []Runnable{
+		{Pipe: drainSourcePipe{drain: drain, after: 5}},
+		{Pipe: result},
+	}).ErrorOrNil()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Frames) != 5 {
+		t.Fatalf("expected 5 frames before the drain but got %d", len(result.Frames))
+	}
+
+	if Drained(context.Background()) != nil {
+		t.Fatal("expected a context without a drain to never be drained")
+	}
+}
+
+func TestDrainReader(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+
+	var (
+		ctx, drain = WithDrain(context.Background())
+		r          = drainReader{file: pr, drained: Drained(ctx)}
+	)
+	defer r.Close()
+	go r.interrupt(ctx)
+	_, _ = pw.WriteString("a")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		drain()
+	}()
+
+	// Copying must use Read so that a drain ends the input instead of failing
+	var b bytes.Buffer
+	_, err = io.Copy(&b, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "a" {
+		t.Fatalf("expected %q but got %q", "a", b.String())
+	}
+}
@@ -0,0 +1,105 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position is a line and column of a pipe script, both starting at 1
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// Error is an error caused by part of a line in a pipe script
+type Error struct {
+	Position
+	// Length is the number of characters of the line that caused the error
+	Length int
+	// Source is the line of the script
+	Source string
+	// Stage is the text of the command that caused the error, if known
+	Stage string
+	// Warning is true if the script can still run despite the error
+	Warning bool
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Stage == "" {
+		return fmt.Sprintf("%s: %v", e.Position, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Position, e.Stage, e.Err)
+}
+
+// Cause returns the underlying error
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Display renders the error with the line of the script that caused it,
+// marking the part of the line that caused the error.
+func (e *Error) Display() string {
+	var (
+		s      strings.Builder
+		number = fmt.Sprint(e.Line)
+		gutter = strings.Repeat(" ", len(number))
+		length = e.Length
+	)
+	if length < 1 {
+		length = 1
+	}
+	if e.Warning {
+		fmt.Fprintf(&s, "warning: %v\n", e.Err)
+	} else {
+		fmt.Fprintf(&s, "error: %v\n", e.Err)
+	}
+	fmt.Fprintf(&s, "%s--> %s\n", gutter, e.Position)
+	fmt.Fprintf(&s, "%s |\n", gutter)
+	fmt.Fprintf(&s, "%s | %s\n", number, e.Source)
+	fmt.Fprintf(&s, "%s | %s%s", gutter, indent(e.Source, e.Column-1), strings.Repeat("^", length))
+	return s.String()
+}
+
+// indent returns the whitespace needed to line up with column n of line, keeping tabs
+func indent(line string, n int) string {
+	var s strings.Builder
+	for i, r := range []rune(line) {
+		if i >= n {
+			break
+		}
+		if r == '\t' {
+			s.WriteRune('\t')
+		} else {
+			s.WriteRune(' ')
+		}
+	}
+	for i := len([]rune(line)); i < n; i++ {
+		s.WriteRune(' ')
+	}
+	return s.String()
+}
+
+// causer is an error that wraps another error
+type causer interface {
+	Cause() error
+}
+
+// AsError returns the first Error wrapped by err
+func AsError(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+		c, ok := err.(causer)
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+	return nil, false
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/SteelSeries/bufrr"
+	"github.com/pkg/errors"
 	"io"
 	"strings"
 	"unicode"
@@ -16,7 +17,10 @@ type Node interface {
 }
 
 type Tag struct {
-	b bytes.Buffer
+	b      bytes.Buffer
+	m      *Modifiers
+	nested bool
+	branch bool
 }
 
 func (t *Tag) String() string {
@@ -26,7 +30,19 @@ func (t *Tag) String() string {
 	return fmt.Sprintf("as %s", t.b.String())
 }
 
+// after returns an error if anything other than modifiers was written after the tag
+func (t *Tag) after(word *bytes.Buffer) error {
+	if word.Len() > 0 {
+		return errors.Errorf("unexpected %q after tag", word.String())
+	}
+	return nil
+}
+
 func (t *Tag) Read(b bufrr.RunePeeker) error {
+	var (
+		end  bool         // the tag has been fully read
+		word bytes.Buffer // the word following the tag
+	)
 	for {
 		r, _, err := b.PeekRune()
 		if err != nil {
@@ -38,10 +54,37 @@ func (t *Tag) Read(b bufrr.RunePeeker) error {
 			if t.b.Len() == 0 {
 				return io.ErrUnexpectedEOF
 			}
+			if err := t.after(&word); err != nil {
+				return err
+			}
 			return io.EOF
 		case IsNextPipe(b):
+			if err := t.after(&word); err != nil {
+				return err
+			}
 			return EOP
+		case t.nested && IsEndGroup(b):
+			if t.b.Len() == 0 {
+				return io.ErrUnexpectedEOF
+			}
+			if err := t.after(&word); err != nil {
+				return err
+			}
+			return EOG
+		case t.branch && end && word.Len() == 0 && IsStartGroup(b):
+			return EOB
 		case unicode.IsSpace(r):
+			if word.Len() > 0 {
+				if word.String() != "with" {
+					return t.after(&word)
+				}
+				b.ReadRune()
+				t.m.nested = t.nested
+				return t.m.Read(b)
+			}
+			end = t.b.Len() > 0
+		case end:
+			word.WriteRune(r)
 		default:
 			t.b.WriteRune(r)
 		}
@@ -50,15 +93,181 @@ func (t *Tag) Read(b bufrr.RunePeeker) error {
 	}
 }
 
+// Modifier is a single key or key=value pair given to a command in its modifiers
+type Modifier struct {
+	Key   string
+	Value string
+}
+
+func (m Modifier) String() string {
+	if m.Value == "" {
+		return m.Key
+	}
+	return fmt.Sprintf("%s=%s", m.Key, m.Value)
+}
+
+// Modifiers change how a command is run, written as a list of modifiers after the with keyword.
+type Modifiers struct {
+	b      bytes.Buffer
+	m      []Modifier
+	nested bool
+}
+
+func (m *Modifiers) String() string {
+	if len(m.m) == 0 {
+		return ""
+	}
+	var parts = make([]string, len(m.m))
+	for i, x := range m.m {
+		parts[i] = x.String()
+	}
+	return fmt.Sprintf("with %s", strings.Join(parts, " "))
+}
+
+// flush adds the current word as a modifier
+func (m *Modifiers) flush() {
+	if m.b.Len() == 0 {
+		return
+	}
+	parts := strings.SplitN(m.b.String(), "=", 2)
+	x := Modifier{
+		Key: parts[0],
+	}
+	if len(parts) > 1 {
+		x.Value = parts[1]
+	}
+	m.m = append(m.m, x)
+	m.b.Reset()
+}
+
+// end completes reading the modifiers, returning err if at least one modifier was read
+func (m *Modifiers) end(err error) error {
+	m.flush()
+	if len(m.m) == 0 {
+		return errors.New("expected at least one modifier after with")
+	}
+	return err
+}
+
+func (m *Modifiers) Read(b bufrr.RunePeeker) error {
+	for {
+		r, _, err := b.PeekRune()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case IsEOF(b):
+			return m.end(io.EOF)
+		case IsNextPipe(b):
+			return m.end(EOP)
+		case m.nested && IsEndGroup(b):
+			return m.end(EOG)
+		case unicode.IsSpace(r):
+			m.flush()
+		default:
+			m.b.WriteRune(r)
+		}
+
+		b.ReadRune()
+	}
+}
+
 type Arg struct {
-	b bytes.Buffer
-	t Tag
+	b      bytes.Buffer
+	t      Tag
+	m      Modifiers
+	nested bool
+	branch bool
+	depth  int // depth of brackets opened within the argument
+	pos    int // position of the start of the argument
+	with   int // the length of the argument up to a with keyword that may start the modifiers, if any
 }
 
 func (a *Arg) String() string {
 	return a.b.String()
 }
 
+// trimSpace removes trailing whitespace from the argument
+func (a *Arg) trimSpace() {
+	trimmed := strings.TrimRightFunc(a.b.String(), unicode.IsSpace)
+	a.b.Reset()
+	a.b.WriteString(trimmed)
+}
+
+// endsWith returns true if the last word of the argument is keyword
+func (a *Arg) endsWith(keyword string) bool {
+	s := a.b.String()
+	if !strings.HasSuffix(s, keyword) {
+		return false
+	}
+	s = strings.TrimSuffix(s, keyword)
+	return s == "" || strings.HasSuffix(s, " ")
+}
+
+// quoted returns true if the end of the argument is within quotes or a template
+func (a *Arg) quoted() bool {
+	var (
+		quote rune
+		lr    rune
+		depth int
+	)
+	for _, r := range a.b.String() {
+		switch {
+		case quote == '\'':
+			if r == quote {
+				quote = 0
+			}
+		case lr == '\\':
+			// An escaped character has no special meaning
+			r = 0
+		case quote == '"':
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			if depth == 0 {
+				quote = r
+			}
+		case lr == '{' && (r == '{' || r == '%'):
+			depth++
+		case (lr == '}' || lr == '%') && r == '}' && depth > 0:
+			depth--
+		}
+		lr = r
+	}
+	return quote != 0 || depth > 0
+}
+
+// modifier returns the word written after a with keyword and true if it is a known modifier
+func (a *Arg) modifier() (string, bool) {
+	if a.with == 0 {
+		return "", false
+	}
+	word := strings.TrimSpace(a.b.String()[a.with:])
+	key := strings.SplitN(word, "=", 2)[0]
+	return word, ModifierKeys[key]
+}
+
+// startModifiers moves the first modifier and the with keyword before it from the argument into its modifiers
+func (a *Arg) startModifiers(word string) {
+	a.b.Truncate(a.with - len("with"))
+	a.trimSpace()
+	a.with = 0
+	a.m.nested = a.nested
+	a.m.b.WriteString(word)
+	a.m.flush()
+}
+
+// end completes reading the argument with err, reading any modifiers started by a with keyword
+func (a *Arg) end(err error) error {
+	if word, ok := a.modifier(); ok {
+		a.startModifiers(word)
+		return a.m.end(err)
+	}
+	return err
+}
+
 func (a *Arg) Read(b bufrr.RunePeeker) error {
 	var lr rune
 	for {
@@ -69,13 +278,47 @@ func (a *Arg) Read(b bufrr.RunePeeker) error {
 
 		switch {
 		case IsEOF(b):
-			return io.EOF
+			return a.end(io.EOF)
 		case IsNextPipe(b):
-			return EOP
+			return a.end(EOP)
+		case a.nested && a.depth == 0 && IsEndGroup(b):
+			// Whitespace before the closing bracket is not part of the argument
+			a.trimSpace()
+			return a.end(EOG)
+		case a.branch && a.depth == 0 && (a.b.Len() == 0 || unicode.IsSpace(lr)) && IsStartGroup(b):
+			a.trimSpace()
+			return EOB
 		case (a.b.Len() == 0 || unicode.IsSpace(lr)) && IsStartTag(b):
+			a.t.nested = a.nested
+			a.t.branch = a.branch
+			a.t.m = &a.m
 			return a.t.Read(b)
 		case a.b.Len() == 0 && unicode.IsSpace(r):
+		case a.depth == 0 && unicode.IsSpace(r) && !a.quoted():
+			// with starts the modifiers only if the word after it is a known modifier
+			word, ok := a.modifier()
+			if ok {
+				b.ReadRune()
+				a.startModifiers(word)
+				return a.m.Read(b)
+			}
+			if word != "" {
+				a.with = 0
+			}
+			if a.with == 0 && a.endsWith("with") {
+				a.with = a.b.Len()
+			}
+			a.b.WriteRune(r)
 		default:
+			if a.b.Len() == 0 {
+				a.pos = position(b)
+			}
+			switch r {
+			case '(':
+				a.depth++
+			case ')':
+				a.depth--
+			}
 			a.b.WriteRune(r)
 		}
 
@@ -83,9 +326,28 @@ func (a *Arg) Read(b bufrr.RunePeeker) error {
 	}
 }
 
+// endOf returns the position of the end of a command after it was read from b.
+// The token that ended the command is not included.
+func endOf(b bufrr.RunePeeker, err error) int {
+	switch err {
+	case EOP:
+		return position(b) - 2
+	case EOG, EOB:
+		return position(b) - 1
+	}
+	return position(b)
+}
+
 type Command struct {
-	b    bytes.Buffer
-	Args Arg
+	b        bytes.Buffer
+	Args     Arg
+	group    *Pipe
+	branches []*Command
+	nested   bool
+	branch   bool
+	pos      int
+	end      int     // position of the end of the command
+	script   *Script // the script the command was read from
 }
 
 func (c *Command) Name() string {
@@ -96,8 +358,158 @@ func (c *Command) Tag() string {
 	return c.Args.t.b.String()
 }
 
+// Group returns the commands of the sub-pipeline if this command is a bracketed group.
+// Otherwise it returns nil.
+func (c *Command) Group() []*Command {
+	if c.group == nil {
+		return nil
+	}
+	return c.group.pipes
+}
+
+// Branches returns the bracketed branches given to a branching command.
+// Each branch is a group command.
+func (c *Command) Branches() []*Command {
+	return c.branches
+}
+
+// Modifiers returns the modifiers given to this command after the with keyword.
+func (c *Command) Modifiers() map[string]string {
+	var m = make(map[string]string, len(c.Args.m.m))
+	for _, x := range c.Args.m.m {
+		m[x.Key] = x.Value
+	}
+	return m
+}
+
+// Pos returns the character position of the start of this command in the input.
+func (c *Command) Pos() int {
+	return c.pos
+}
+
+// attach sets the script of this command and all of its sub-commands
+func (c *Command) attach(s *Script) {
+	c.script = s
+	for _, x := range c.Group() {
+		x.attach(s)
+	}
+	for _, x := range c.branches {
+		x.attach(s)
+	}
+}
+
+// Text returns the text of this command as it was written in the script
+func (c *Command) Text() string {
+	if c.script == nil {
+		return c.String()
+	}
+	var text = []rune(c.script.String())
+	if c.pos > c.end || c.end > len(text) {
+		return c.String()
+	}
+	return strings.TrimSpace(string(text[c.pos:c.end]))
+}
+
+// Err annotates err with the position and text of this command in its script.
+// Errors already annotated by a command within this command are returned unchanged.
+func (c *Command) Err(err error) error {
+	if _, ok := AsError(err); ok || c.script == nil || err == nil {
+		return err
+	}
+	text := c.Text()
+	return c.script.Error(c.pos, len([]rune(text)), text, err)
+}
+
+// ArgErr annotates err with the position of the first instance of token in the arguments of this command.
+// If token is not found then the error spans the whole command.
+func (c *Command) ArgErr(token string, err error) error {
+	if _, ok := AsError(err); ok {
+		return err
+	}
+	args := c.Args.String()
+	i := strings.Index(args, token)
+	if token == "" || i < 0 || c.script == nil {
+		return c.Err(err)
+	}
+	return c.script.Error(c.Args.pos+len([]rune(args[:i])), len([]rune(token)), c.Text(), err)
+}
+
 func (c *Command) String() string {
-	return fmt.Sprintf("%s %s%s", c.b.String(), c.Args.String(), c.Args.t.String())
+	var s string
+	switch {
+	case c.group != nil && c.Args.t.b.Len() == 0:
+		s = fmt.Sprintf("( %s )", c.group.String())
+	case c.group != nil:
+		s = fmt.Sprintf("( %s ) %s", c.group.String(), c.Args.t.String())
+	case c.branches != nil:
+		var parts = []string{c.b.String()}
+		if c.Args.b.Len() > 0 {
+			parts = append(parts, c.Args.String())
+		}
+		for _, branch := range c.branches {
+			parts = append(parts, branch.String())
+		}
+		s = strings.Join(parts, " ")
+	default:
+		s = fmt.Sprintf("%s %s%s", c.b.String(), c.Args.String(), c.Args.t.String())
+	}
+	if len(c.Args.m.m) > 0 {
+		if !strings.HasSuffix(s, " ") {
+			s += " "
+		}
+		s += c.Args.m.String()
+	}
+	return s
+}
+
+// readGroup reads a bracketed sub-pipeline and its optional tag
+func (c *Command) readGroup(b bufrr.RunePeeker) error {
+	c.group = &Pipe{
+		nested: true,
+		pos:    c.pos,
+	}
+	err := c.group.Read(b)
+	if err != nil {
+		return err
+	}
+
+	c.Args.nested = c.nested
+	c.Args.branch = c.branch
+	err = c.Args.Read(b)
+	if c.Args.b.Len() > 0 {
+		return &PosError{
+			Pos:  c.pos,
+			Rune: '(',
+			Err:  errors.Errorf("unexpected arguments %q after sub-pipeline", strings.TrimSpace(c.Args.b.String())),
+		}
+	}
+	return err
+}
+
+// readBranches reads the options of a branching command followed by all of its branches.
+// If no branches are given then the command is read as a normal command.
+func (c *Command) readBranches(b bufrr.RunePeeker) error {
+	c.Args.nested = c.nested
+	c.Args.branch = true
+	err := c.Args.Read(b)
+	for err == EOB {
+		var branch = &Command{
+			nested: c.nested,
+			branch: true,
+			pos:    position(b) - 1,
+		}
+		err = branch.readGroup(b)
+		branch.end = endOf(b, err)
+		c.branches = append(c.branches, branch)
+	}
+	if len(c.branches) == 0 {
+		return err
+	}
+
+	// Modifiers after the last branch apply to the whole command
+	last := c.branches[len(c.branches)-1]
+	c.Args.m.m, last.Args.m.m = last.Args.m.m, nil
+	return err
 }
 
 func (c *Command) Read(b bufrr.RunePeeker) error {
@@ -106,6 +518,9 @@ func (c *Command) Read(b bufrr.RunePeeker) error {
 		if err != nil {
 			return err
 		}
+		if c.b.Len() == 0 {
+			c.pos = position(b)
+		}
 
 		switch {
 		case IsEOF(b):
@@ -115,9 +530,17 @@ func (c *Command) Read(b bufrr.RunePeeker) error {
 			return io.EOF
 		case IsNextPipe(b):
 			return EOP
+		case c.nested && IsEndGroup(b):
+			return EOG
+		case c.b.Len() == 0 && IsStartGroup(b):
+			return c.readGroup(b)
 		case c.b.Len() == 0 && unicode.IsSpace(r):
 		case c.b.Len() > 0 && unicode.IsSpace(r):
 			b.ReadRune()
+			if Branching[c.b.String()] {
+				return c.readBranches(b)
+			}
+			c.Args.nested = c.nested
 			return c.Args.Read(b)
 		default:
 			c.b.WriteRune(r)
@@ -128,7 +551,9 @@ func (c *Command) Read(b bufrr.RunePeeker) error {
 }
 
 type Pipe struct {
-	pipes []*Command
+	pipes  []*Command
+	nested bool
+	pos    int // position of the opening bracket of a nested pipe
 }
 
 func (p *Pipe) String() string {
@@ -143,14 +568,31 @@ func (p *Pipe) String() string {
 }
 
 func (p *Pipe) Read(b bufrr.RunePeeker) error {
-	r := &RuneSeekPointer{
-		RunePeeker: b,
+	// Nested pipes share the pointer of their parent so that positions are relative to the whole input
+	r, ok := b.(*RuneSeekPointer)
+	if !ok {
+		r = &RuneSeekPointer{
+			RunePeeker: b,
+		}
 	}
 	for {
-		var c = new(Command)
-		err := c.Read(b)
+		var c = &Command{
+			nested: p.nested,
+		}
+		err := c.Read(r)
+		c.end = endOf(r, err)
 		switch err {
 		case io.EOF:
+			if p.nested {
+				return &PosError{
+					Pos:  p.pos,
+					Rune: '(',
+					Err:  errors.New("sub-pipeline is missing a closing ')'"),
+				}
+			}
+			p.pipes = append(p.pipes, c)
+			return nil
+		case EOG:
 			p.pipes = append(p.pipes, c)
 			return nil
 		case EOP:
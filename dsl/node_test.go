@@ -33,9 +33,83 @@ func TestPipe_Read(t *testing.T) {
 			With:   new(Pipe),
 			Expect: "Args one two three as foo :: b four five six as bar",
 		},
+		{
+			Using:  "open *.json :: ( json :: select this.id ) as ids :: print {{ids}}",
+			With:   new(Pipe),
+			Expect: "open *.json  :: ( json  :: select this.id ) as ids :: print {{ids}}",
+		},
+		{
+			Using:  "url.get {{this}} with jobs=8 unordered :: json as x with jobs=2",
+			With:   new(Pipe),
+			Expect: "url.get {{this}} with jobs=8 unordered :: json as x with jobs=2",
+		},
+		{
+			Using:  "(a with jobs=2) as y with jobs=3",
+			With:   new(Pipe),
+			Expect: "( a with jobs=2 ) as y with jobs=3",
+		},
+		{
+			Using:  "a :: tee -drop=true (b) as x (c :: d) as y with jobs=2 :: e",
+			With:   new(Pipe),
+			Expect: "a  :: tee -drop=true ( b  ) as x ( c  :: d  ) as y with jobs=2 :: e ",
+		},
+		{
+			Using:  "tee out.txt",
+			With:   new(Pipe),
+			Expect: "tee out.txt",
+		},
+		{
+			Using:  "print hello with world :: exec echo 'a with jobs=2' \"b with jobs=2\"",
+			With:   new(Pipe),
+			Expect: "print hello with world  :: exec echo 'a with jobs=2' \"b with jobs=2\"",
+		},
+		{
+			Using:  "print {{ x with jobs=2 }} {% with a=1 %}{{a}}{% endwith %} with jobs=2",
+			With:   new(Pipe),
+			Expect: "print {{ x with jobs=2 }} {% with a=1 %}{{a}}{% endwith %} with jobs=2",
+		},
+		{
+			Using:  "print a with with  jobs=2 :: print with :: (print b with) as x",
+			With:   new(Pipe),
+			Expect: "print a with with jobs=2 :: print with  :: ( print b with ) as x",
+		},
+		{
+			Using:  "(a (b) :: (c as x)) as y",
+			With:   new(Pipe),
+			Expect: "( a (b)  :: ( c as x ) ) as y",
+		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.Using, tc.Run)
 	}
 }
+
+func TestPipe_ReadInvalid(t *testing.T) {
+	cases := []struct {
+		Using string
+		Pos   int
+	}{
+		{Using: "a :: ( b :: c", Pos: 5},
+		{Using: "a :: ( b :: ( c ) x )", Pos: 12},
+		{Using: "a :: ( b as )", Pos: 13},
+		{Using: "a as b c", Pos: 8},
+		{Using: "a as b with :: c", Pos: 14},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Using, func(t *testing.T) {
+			err := new(Pipe).Read(bufrr.NewReader(strings.NewReader(tc.Using)))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			pe, ok := err.(*PosError)
+			if !ok {
+				t.Fatalf("expected %T but got %T", pe, err)
+			}
+			if pe.Pos != tc.Pos {
+				t.Fatalf("expected error at character %d but got %d (%v)", tc.Pos, pe.Pos, err)
+			}
+		})
+	}
+}
@@ -3,11 +3,25 @@ package dsl
 import (
 	"github.com/SteelSeries/bufrr"
 	"io"
+	"strings"
 )
 
+// Parse reads a pipe script into the list of commands in the pipeline.
+// See ReadScript for how scripts written over many lines are read.
 func Parse(r io.Reader) ([]*Command, error) {
+	script, err := ReadScript(r)
+	if err != nil {
+		return nil, err
+	}
+
 	var p = new(Pipe)
-	var b = bufrr.NewReader(r)
-	var err = p.Read(b)
+	var b = bufrr.NewReader(strings.NewReader(script.String()))
+	err = p.Read(b)
+	if e, ok := err.(*PosError); ok {
+		return nil, script.Error(e.Pos, 1, "", e.Err)
+	}
+	for _, c := range p.pipes {
+		c.attach(script)
+	}
 	return p.pipes, err
 }
@@ -1,12 +1,28 @@
 package dsl
 
 import (
+	"fmt"
 	"github.com/SteelSeries/bufrr"
-	"github.com/pkg/errors"
 )
 
 var _ bufrr.RunePeeker = (*RuneSeekPointer)(nil)
 
+// PosError is an error that occurred at a known position of the input
+type PosError struct {
+	Pos  int
+	Rune rune
+	Err  error
+}
+
+func (e *PosError) Error() string {
+	return fmt.Sprintf("at character %d (%q): %v", e.Pos, e.Rune, e.Err)
+}
+
+// Cause returns the underlying error
+func (e *PosError) Cause() error {
+	return e.Err
+}
+
 type RuneSeekPointer struct {
 	bufrr.RunePeeker
 
@@ -18,9 +34,19 @@ func (rsp *RuneSeekPointer) Pos() int {
 	return rsp.char
 }
 
+// Err annotates err with the current position of the reader.
+// Errors that already carry a position are returned as-is so that
+// errors inside of a sub-pipeline keep the position they occurred at.
 func (rsp *RuneSeekPointer) Err(err error) error {
+	if _, ok := err.(*PosError); ok {
+		return err
+	}
 	r, _, _ := rsp.RunePeeker.PeekRune()
-	return errors.Wrapf(err, "at character %d (%q)", rsp.Pos(), r)
+	return &PosError{
+		Pos:  rsp.Pos(),
+		Rune: r,
+		Err:  err,
+	}
 }
 
 func (rsp *RuneSeekPointer) UnreadRune() (err error) {
@@ -40,3 +66,11 @@ func (rsp *RuneSeekPointer) ReadRune() (r rune, size int, err error) {
 
 	return
 }
+
+// position returns the current position of b if b is a RuneSeekPointer
+func position(b bufrr.RunePeeker) int {
+	if rsp, ok := b.(*RuneSeekPointer); ok {
+		return rsp.Pos()
+	}
+	return 0
+}
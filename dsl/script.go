@@ -0,0 +1,134 @@
+package dsl
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// continues returns true if a script line following a line ending in prev continues the same command
+func continues(prev, next string) bool {
+	switch {
+	case strings.HasSuffix(prev, "::"), strings.HasSuffix(prev, "("):
+		return true
+	case strings.HasPrefix(next, "::"), strings.HasPrefix(next, ")"):
+		return true
+	case strings.HasPrefix(next, "as "), strings.HasPrefix(next, "with "):
+		return true
+	}
+	return false
+}
+
+// Script is a pipe script read into a single pipeline.
+// It remembers where each character of the pipeline came from in the original script.
+type Script struct {
+	text  strings.Builder
+	lines []string
+	pos   []Position // the position of each rune of the text in the original script
+}
+
+func (s *Script) String() string {
+	return s.text.String()
+}
+
+// write adds text starting at pos to the pipeline
+func (s *Script) write(text string, pos Position) {
+	for _, r := range text {
+		s.text.WriteRune(r)
+		s.pos = append(s.pos, pos)
+		pos.Column++
+	}
+}
+
+// Position returns the position in the original script of the rune at offset in the pipeline
+func (s *Script) Position(offset int) Position {
+	switch {
+	case offset < 0 || len(s.pos) == 0:
+		return Position{Line: 1, Column: 1}
+	case offset >= len(s.pos):
+		p := s.pos[len(s.pos)-1]
+		p.Column++
+		return p
+	}
+	return s.pos[offset]
+}
+
+// Line returns line n of the original script
+func (s *Script) Line(n int) string {
+	if n < 1 || n > len(s.lines) {
+		return ""
+	}
+	return s.lines[n-1]
+}
+
+// Error creates an error spanning length runes from offset in the pipeline.
+// If stage is not empty then it is the text of the command that caused the error.
+func (s *Script) Error(offset, length int, stage string, err error) *Error {
+	p := s.Position(offset)
+	end := s.Position(offset + length - 1)
+	if end.Line != p.Line || length < 1 {
+		end = p
+	}
+	return &Error{
+		Position: p,
+		Length:   end.Column - p.Column + 1,
+		Source:   s.Line(p.Line),
+		Stage:    stage,
+		Err:      err,
+	}
+}
+
+// ReadScript reads a pipe script that may be written over many lines into a single pipeline.
+//
+// Blank lines and lines starting with # (including a #! shebang) are ignored.
+// Each line is a new command in the pipeline unless
+//
+//	the line ends with \, ::, or (
+//	the next line starts with ::, ), as or with
+//
+// in which case the next line continues the same command.
+func ReadScript(r io.Reader) (*Script, error) {
+	var (
+		s         = bufio.NewScanner(r)
+		script    = new(Script)
+		prev      string
+		end       Position // the position following the previous line
+		continued bool
+	)
+	for n := 1; s.Scan(); n++ {
+		source := strings.TrimRight(s.Text(), "\r")
+		script.lines = append(script.lines, source)
+
+		line := strings.TrimSpace(source)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch {
+		case script.text.Len() == 0:
+		case continued || continues(prev, line):
+			script.write(" ", end)
+		default:
+			script.write(" :: ", end)
+		}
+
+		continued = strings.HasSuffix(line, `\`)
+		if continued {
+			line = strings.TrimSpace(strings.TrimSuffix(line, `\`))
+		}
+
+		indent := len([]rune(source)) - len([]rune(strings.TrimLeftFunc(source, unicode.IsSpace)))
+		start := Position{
+			Line:   n,
+			Column: indent + 1,
+		}
+		script.write(line, start)
+		prev = line
+		end = Position{
+			Line:   n,
+			Column: start.Column + len([]rune(line)),
+		}
+	}
+	return script, s.Err()
+}
@@ -0,0 +1,90 @@
+package dsl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadScript(t *testing.T) {
+	cases := []struct {
+		Using  string
+		Expect string
+	}{
+		{
+			Using:  "json :: flatten",
+			Expect: "json :: flatten",
+		},
+		{
+			Using:  "#!/usr/bin/env pipe\n# decode\njson\n\n  # then flatten\n  flatten as o\nprint {{o.b}}\n",
+			Expect: "json :: flatten as o :: print {{o.b}}",
+		},
+		{
+			Using:  "url.get \\\n  http://example.com\n  with jobs=2\njson ::\n  flatten\n  :: print",
+			Expect: "url.get http://example.com with jobs=2 :: json :: flatten :: print",
+		},
+		{
+			Using:  "json\n(\n  flatten\n  print {{this}}\n)\n  as x\nprint {{x}}",
+			Expect: "json :: ( flatten :: print {{this}} ) as x :: print {{x}}",
+		},
+		{
+			Using:  "\r\nprint a\r\n",
+			Expect: "print a",
+		},
+	}
+	for _, tc := range cases {
+		got, err := ReadScript(strings.NewReader(tc.Using))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != tc.Expect {
+			t.Fatalf("Wanted %q but got %q", tc.Expect, got)
+		}
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	_, err := Parse(strings.NewReader("# comment\njson\n  ( flatten\nprint"))
+	e, ok := AsError(err)
+	if !ok {
+		t.Fatalf("expected %T but got %T (%v)", e, err, err)
+	}
+	if e.Line != 3 || e.Column != 3 {
+		t.Fatalf("expected error at line 3, column 3 but got %s", e.Position)
+	}
+
+	var expect = "error: sub-pipeline is missing a closing ')'\n" +
+		" --> line 3, column 3\n" +
+		"  |\n" +
+		"3 |   ( flatten\n" +
+		"  |   ^"
+	if got := e.Display(); got != expect {
+		t.Fatalf("Wanted\n%s\nbut got\n%s", expect, got)
+	}
+}
+
+func TestCommand_Err(t *testing.T) {
+	commands, err := Parse(strings.NewReader("json ::\n\tflatten -x :: print"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := AsError(commands[1].ArgErr("-x", errors.New("unknown option -x")))
+	if !ok {
+		t.Fatal("expected an annotated error")
+	}
+	if e.Line != 2 || e.Column != 10 || e.Length != 2 {
+		t.Fatalf("expected error at line 2, column 10 for 2 characters but got %s for %d", e.Position, e.Length)
+	}
+	if e.Stage != "flatten -x" {
+		t.Fatalf("expected stage %q but got %q", "flatten -x", e.Stage)
+	}
+	if !strings.HasSuffix(e.Display(), "2 | \tflatten -x :: print\n  | \t        ^^") {
+		t.Fatalf("expected the caret to line up with the tabbed line but got\n%s", e.Display())
+	}
+
+	e, _ = AsError(commands[2].Err(errors.New("failed")))
+	if e.Stage != "print" || e.Column != 16 {
+		t.Fatalf("expected error for print at column 16 but got %q at %s", e.Stage, e.Position)
+	}
+}
@@ -9,8 +9,32 @@ import (
 var (
 	// EOP is an end of pipe error
 	EOP = errors.New("End of pipe")
+	// EOG is an end of group error, returned when the closing bracket of a sub-pipeline is read
+	EOG = errors.New("End of group")
+	// EOB is an end of branch error, returned when the opening bracket of a branch is read
+	EOB = errors.New("End of branch")
 )
 
+// Branching are the names of commands that take a list of bracketed sub-pipelines as branches.
+var Branching = map[string]bool{
+	"tee":   true,
+	"merge": true,
+}
+
+// ModifierKeys are the keys of the modifiers that can be given after the with keyword.
+// with is only read as a keyword when it is followed by one of these,
+// so that it can still be written as a word in the arguments of a command.
+var ModifierKeys = map[string]bool{
+	"buffer":     true,
+	"jobs":       true,
+	"unordered":  true,
+	"errors":     true,
+	"retries":    true,
+	"backoff":    true,
+	"deadletter": true,
+	"persistent": true,
+}
+
 // IsDoubleRune returns true if the current position of the RunePeeker is Args double instance of rune r.
 // The characters in the RunePeeker are consumed if matched.
 func IsDoubleRune(b bufrr.RunePeeker, x, y rune) bool {
@@ -29,6 +53,17 @@ func IsDoubleRune(b bufrr.RunePeeker, x, y rune) bool {
 	return true
 }
 
+// IsRune returns true if the current position of the RunePeeker is rune x.
+// The character in the RunePeeker is consumed if matched.
+func IsRune(b bufrr.RunePeeker, x rune) bool {
+	p, _, _ := b.PeekRune()
+	if p != x {
+		return false
+	}
+	b.ReadRune()
+	return true
+}
+
 func IsNextPipe(b bufrr.RunePeeker) bool {
 	return IsDoubleRune(b, ':', ':')
 }
@@ -37,6 +72,14 @@ func IsStartTag(b bufrr.RunePeeker) bool {
 	return IsDoubleRune(b, 'a', 's')
 }
 
+func IsStartGroup(b bufrr.RunePeeker) bool {
+	return IsRune(b, '(')
+}
+
+func IsEndGroup(b bufrr.RunePeeker) bool {
+	return IsRune(b, ')')
+}
+
 func IsEOF(b bufrr.RunePeeker) bool {
 	r, _, err := b.PeekRune()
 	return err == io.EOF || r == bufrr.EOF
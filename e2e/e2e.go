@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"github.com/relvacode/pipe"
-	"strings"
 )
 
 type WTestPipe struct {
@@ -51,28 +50,11 @@ func RunPipeTest(inputs []interface{}, pipes []pipe.Runnable) ([]*pipe.DataFrame
 }
 
 func RunConsoleTest(stdin []byte, command string) (string, error) {
-	pipes, err := pipe.Parse(strings.NewReader(command), pipe.Lib)
+	var b = new(bytes.Buffer)
+	p, err := pipe.New(command, pipe.WithObjects(bytes.NewReader(stdin)), pipe.WithWriter(b, pipe.Raw))
 	if err != nil {
 		return "", err
 	}
-
-	exec := make([]pipe.Runnable, len(pipes)+2)
-	copy(exec[1:], pipes)
-	exec[0] = pipe.Runnable{
-		Pipe: &WTestPipe{
-			Objects: []interface{}{
-				bytes.NewReader(stdin),
-			},
-		},
-	}
-
-	var b = new(bytes.Buffer)
-	r := &pipe.EchoPipe{
-		Writer: b,
-	}
-	exec[len(exec)-1] = pipe.Runnable{
-		Pipe: r,
-	}
-	err = pipe.Run(context.Background(), exec).ErrorOrNil()
+	err = p.Run(context.Background())
 	return b.String(), err
 }
@@ -59,6 +59,43 @@ func TestDSL(t *testing.T) {
 			With:   "json :: flatten as o::print {{o.b}}",
 			Expect: "text",
 		},
+		{
+			With:   "json :: flatten :: ( select this.b :: print {{this}}! ) as l :: select l[0]",
+			Expect: "text!",
+		},
+		{
+			With:   "json :: flatten with jobs=4 :: print {{this.b}} with jobs=2",
+			Expect: "text",
+		},
+		{
+			With:   "json :: flatten as o :: ( print {{o.a}} ) as l :: print {{o.b}}",
+			Expect: "text",
+		},
+		{
+			With:   "#!/usr/bin/env pipe\n# decode the input\njson\nflatten \\\n  as o\n\nprint {{o.b}}\n",
+			Expect: "text",
+		},
+	}
+
+	for _, test := range tests {
+		test.Run(t)
+	}
+}
+
+func TestBranching(t *testing.T) {
+	tests := []DSLTest{
+		{
+			With:   "json :: flatten :: tee ( select this.a ) as x ( select this.a * 2 ) as y :: sum this",
+			Expect: "3",
+		},
+		{
+			With:   "merge ( print 1 ) as a ( print 2 :: limit 0 ) as b ( print 3 ) as c :: sum this",
+			Expect: "4",
+		},
+		{
+			With:   "json :: flatten as o :: tee -drop=false ( print {{o.b}} ) as x :: select x",
+			Expect: "text",
+		},
 	}
 
 	for _, test := range tests {
@@ -66,6 +103,54 @@ func TestDSL(t *testing.T) {
 	}
 }
 
+func TestErrorPolicy(t *testing.T) {
+	tests := []DSLTest{
+		{
+			With:   "json :: flatten as o :: select this.b / 2 with errors=skip :: print {{o.b}}",
+			Expect: "",
+		},
+		{
+			With:   "json :: flatten as o :: ( select this.b / 2 ) with errors=skip :: print {{o.b}}",
+			Expect: "",
+		},
+		{
+			With:   "json :: flatten :: select this.b / 2 with deadletter=json :: print x",
+			Expect: "",
+		},
+		{
+			With:   "json :: flatten as o :: select this.a + 1 with errors=retry retries=1 backoff=1ms :: print {{o.b}}",
+			Expect: "text",
+		},
+		{
+			With:   "json :: ( flatten :: sum this.a ) with errors=skip",
+			Expect: "[1]",
+		},
+	}
+
+	for _, test := range tests {
+		test.Run(t)
+	}
+
+	for _, with := range []string{
+		"json :: flatten :: select this.b / 2",
+		"json :: flatten :: select this.b / 2 with errors=retry retries=1 backoff=1ms",
+		"json :: flatten :: select this.b / 2 with errors=dead",
+		"json :: flatten :: select this.b / 2 with errors=explode",
+		"json :: flatten :: select this.b / 2 with deadletter=buffer",
+		"json :: flatten :: select this.b / 2 with deadletter=missing",
+	} {
+		ConsoleTest{
+			With: with,
+			Data: `[{"b": "text"}]`,
+			Expect: func(t *testing.T, result string, err error) {
+				if err == nil {
+					t.Fatalf("expected an error but got %q", result)
+				}
+			},
+		}.Run(t)
+	}
+}
+
 type InvalidParseTest struct {
 	With string
 }
@@ -96,6 +181,18 @@ func TestDSLNoParse(t *testing.T) {
 		{
 			With: "",
 		},
+		{
+			With: "json :: ( flatten",
+		},
+		{
+			With: "json :: ( flatten ) as",
+		},
+		{
+			With: "json :: flatten :: sum this with errors=skip",
+		},
+		{
+			With: "json :: flatten :: limit 2 with retries=1",
+		},
 	}
 
 	for _, test := range tests {
@@ -4,11 +4,12 @@ import (
 	"github.com/relvacode/pipe"
 	"github.com/relvacode/pipe/console"
 	_ "github.com/relvacode/pipe/pipes"
+	"strings"
 	"testing"
 )
 
 func TestLibrary(t *testing.T) {
-	for _, pkg := range pipe.Lib {
+	for _, pkg := range pipe.Lib.Sorted() {
 		t.Run(pkg.Name, func(t *testing.T) {
 			cmd := console.NewCommand()
 
@@ -20,6 +21,27 @@ func TestLibrary(t *testing.T) {
 			t.Run("can usage", func(t *testing.T) {
 				cmd.Usage()
 			})
+			t.Run("has description", func(t *testing.T) {
+				if pkg.Description == "" {
+					t.Fatal("has no description")
+				}
+			})
+			t.Run("examples check", func(t *testing.T) {
+				for _, e := range pkg.Examples {
+					modules, err := pipe.Parse(strings.NewReader(e.Script), pipe.Lib)
+					if err != nil {
+						t.Fatalf("%s: %v", e.Script, err)
+					}
+					for _, w := range pipe.Check(modules) {
+						t.Errorf("%s: %v", e.Script, w)
+					}
+				}
+			})
+			t.Run("can help", func(t *testing.T) {
+				if pipe.Help(pkg) == "" {
+					t.Fatal("produced no help")
+				}
+			})
 		})
 	}
 }
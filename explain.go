@@ -0,0 +1,140 @@
+package pipe
+
+import (
+	"fmt"
+	"github.com/relvacode/pipe/console"
+	"github.com/relvacode/pipe/dsl"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Explain parses a pipe script and describes how each pipe in it is resolved using reg as a tree.
+// Each pipe is shown as native, an alias along with the pipes it expands to,
+// or a program run on the system along with where it was found in PATH.
+// The tag, modifiers and the value of each option of each pipe are shown too.
+func Explain(r io.Reader, reg *Registry) (string, error) {
+	commands, err := dsl.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	_, err = build(commands, reg)
+	if err != nil {
+		return "", err
+	}
+
+	var e = &explainer{
+		reg:     reg,
+		aliases: make(map[string]bool),
+	}
+	e.pipeline(commands, "")
+	return e.s.String(), nil
+}
+
+// explainer writes the tree of a parsed pipe script
+type explainer struct {
+	reg     *Registry
+	s       strings.Builder
+	aliases map[string]bool // aliases currently being expanded
+}
+
+func (e *explainer) line(prefix, text string) {
+	fmt.Fprintf(&e.s, "%s%s\n", prefix, text)
+}
+
+func (e *explainer) pipeline(commands []*dsl.Command, prefix string) {
+	for i, c := range commands {
+		e.command(c, prefix, i == len(commands)-1)
+	}
+}
+
+// describe returns the tag and modifiers of c
+func describe(c *dsl.Command) string {
+	var s strings.Builder
+	if tag := c.Tag(); tag != "" {
+		fmt.Fprintf(&s, " as %s", tag)
+	}
+	if modifiers := c.Modifiers(); len(modifiers) > 0 {
+		var m = make([]string, 0, len(modifiers))
+		for k, v := range modifiers {
+			if v == "" {
+				m = append(m, k)
+			} else {
+				m = append(m, k+"="+v)
+			}
+		}
+		sort.Strings(m)
+		fmt.Fprintf(&s, " with %s", strings.Join(m, " "))
+	}
+	return s.String()
+}
+
+func (e *explainer) command(c *dsl.Command, prefix string, last bool) {
+	var branch, next = "├── ", "│   "
+	if last {
+		branch, next = "└── ", "    "
+	}
+
+	switch {
+	case c.Branches() != nil:
+		e.line(prefix+branch, fmt.Sprintf("%s%s [branching]", c.Name(), describe(c)))
+		if args := c.Args.String(); args != "" {
+			e.line(prefix+next, "  arguments: "+args)
+		}
+		e.pipeline(c.Branches(), prefix+next)
+	case c.Group() != nil:
+		e.line(prefix+branch, fmt.Sprintf("( )%s [group]", describe(c)))
+		e.pipeline(c.Group(), prefix+next)
+	default:
+		e.pipe(c, prefix+branch, prefix+next)
+	}
+}
+
+// pipe writes a single pipe, where prefix is used for its own line and next for everything under it
+func (e *explainer) pipe(c *dsl.Command, prefix, next string) {
+	var (
+		name    = c.Name()
+		pkg, ok = e.reg.Lookup(name)
+		from    string
+	)
+	switch {
+	case !ok:
+		pkg = NewExecPkg(name)
+		path, err := exec.LookPath(name)
+		if err != nil {
+			from = "exec, not found in PATH"
+		} else {
+			from = "exec " + path
+		}
+	case pkg.Alias != "":
+		from = "alias"
+	case pkg.Plugin != "":
+		from = "plugin " + pkg.Plugin
+	default:
+		from = "native"
+	}
+	e.line(prefix, fmt.Sprintf("%s%s [%s]", name, describe(c), from))
+
+	var cmd = console.NewCommand()
+	pkg.Constructor(cmd)
+	if cmd.Set(c.Args.String()) == nil {
+		for _, o := range cmd.Options() {
+			if v := strings.TrimSpace(o.Value()); v != "" {
+				e.line(next, fmt.Sprintf("  %s: %s", o.Label(), v))
+			}
+		}
+	}
+
+	if pkg.Alias == "" || e.aliases[name] {
+		return
+	}
+	commands, err := dsl.Parse(strings.NewReader(pkg.Alias))
+	if err != nil {
+		e.line(next, fmt.Sprintf("  invalid alias: %v", err))
+		return
+	}
+	e.aliases[name] = true
+	e.pipeline(commands, next)
+	delete(e.aliases, name)
+}
@@ -0,0 +1,58 @@
+package pipe
+
+import (
+	"github.com/relvacode/pipe/console"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found in PATH")
+	}
+
+	var reg = NewRegistry(
+		Pkg{
+			Name: "test",
+			Constructor: func(cmd *console.Command) Pipe {
+				cmd.Option("n").Default(1).Int()
+				cmd.Arg(0).Default("").String()
+				return TestPipe{}
+			},
+		},
+		Pkg{
+			Name:  "alias",
+			Alias: "test -n 2 a",
+			Constructor: func(*console.Command) Pipe {
+				return TestPipe{}
+			},
+		},
+	)
+
+	tree, err := Explain(strings.NewReader("test x as t with jobs=2 :: alias :: ( sh -c true ) as g :: tee ( no-such-program-for-pipe ) as a ( test ) as b"), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expect = "├── test as t with jobs=2 [native]\n" +
+		"│     option -n: 1\n" +
+		"│     argument 0: x\n" +
+		"├── alias [alias]\n" +
+		"│   └── test [native]\n" +
+		"│         option -n: 2\n" +
+		"│         argument 0: a\n" +
+		"├── ( ) as g [group]\n" +
+		"│   └── sh [exec " + sh + "]\n" +
+		"│         arguments: -c true\n" +
+		"└── tee [branching]\n" +
+		"    ├── ( ) as a [group]\n" +
+		"    │   └── no-such-program-for-pipe [exec, not found in PATH]\n" +
+		"    └── ( ) as b [group]\n" +
+		"        └── test [native]\n" +
+		"              option -n: 1\n"
+	if tree != expect {
+		t.Fatalf("Wanted\n%s\nbut got\n%s", expect, tree)
+	}
+}
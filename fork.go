@@ -95,9 +95,11 @@ func (p SubPipe) setup(stream Stream) []Runnable {
 }
 
 func (p SubPipe) Go(ctx context.Context, stream Stream) error {
-	return Run(ctx, p.setup(stream))
+	return Run(ctx, p.setup(stream)).ErrorOrNil()
 }
 
+// ForkPipe runs its sub-pipeline once for each input frame
+// and writes all of the values produced by that sub-pipeline as one list.
 type ForkPipe []Runnable
 
 func (p ForkPipe) Go(ctx context.Context, stream Stream) error {
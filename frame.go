@@ -3,6 +3,7 @@ package pipe
 import (
 	"fmt"
 	"github.com/flosch/pongo2"
+	"github.com/relvacode/pipe/tap"
 	"io"
 )
 
@@ -40,6 +41,7 @@ type DataFrame struct {
 	Stack  Stack
 
 	context pongo2.Context // cached context
+	scope   *tap.Scope     // the scope of the run that created this frame, if any
 }
 
 func (f *DataFrame) String() string {
@@ -67,6 +69,7 @@ func (f *DataFrame) Copy(x interface{}, tag *Tag) *DataFrame {
 		Tag:    tag,
 		Object: x,
 		Stack:  make(Stack, len(f.Stack)),
+		scope:  f.scope,
 	}
 	for k, v := range f.Stack {
 		n.Stack[k] = v
@@ -105,5 +108,8 @@ func (f *DataFrame) Context() pongo2.Context {
 	}
 	f.context["this"] = f.Object
 	f.context["_index"] = f.Index
+	if f.scope != nil {
+		f.context[tap.ScopeKey] = f.scope
+	}
 	return f.context
 }
@@ -0,0 +1,84 @@
+package pipe
+
+import (
+	"fmt"
+	"github.com/relvacode/pipe/console"
+	"strings"
+)
+
+// kinds formats a list of kinds separated by sep, where no kinds is any kind
+func kinds(k []Kind, sep string) string {
+	if len(k) == 0 {
+		return string(Any)
+	}
+	var s = make([]string, len(k))
+	for i, x := range k {
+		s[i] = string(x)
+	}
+	return strings.Join(s, sep)
+}
+
+// Summary returns a single line describing a pipe, with its name, usage and description separated by tabs
+func Summary(pkg Pkg) string {
+	cmd := console.NewCommand()
+	pkg.Constructor(cmd)
+	return fmt.Sprintf("%s\t%s\t%s", pkg.Name, cmd.Usage(), pkg.Description)
+}
+
+// Help returns the full help text for a pipe
+func Help(pkg Pkg) string {
+	cmd := console.NewCommand()
+	pkg.Constructor(cmd)
+
+	var s strings.Builder
+	section := func(title string) {
+		if s.Len() > 0 {
+			s.WriteString("\n")
+		}
+		fmt.Fprintf(&s, "%s\n", title)
+	}
+
+	section("NAME")
+	if pkg.Description == "" {
+		fmt.Fprintf(&s, "    %s\n", pkg.Name)
+	} else {
+		fmt.Fprintf(&s, "    %s - %s\n", pkg.Name, pkg.Description)
+	}
+
+	section("SYNOPSIS")
+	fmt.Fprintf(&s, "    %s %s\n", pkg.Name, cmd.Usage())
+
+	if options := cmd.Options(); len(options) > 0 {
+		section("OPTIONS")
+		for _, o := range options {
+			if o.IsFlag() {
+				fmt.Fprintf(&s, "    -%s %s\n", o.Name(), o.Usage())
+			} else {
+				fmt.Fprintf(&s, "    %s\n", o.Usage())
+			}
+			if o.Description() != "" {
+				fmt.Fprintf(&s, "        %s\n", o.Description())
+			}
+		}
+	}
+
+	section("INPUT")
+	fmt.Fprintf(&s, "    %s\n", kinds(pkg.Input, ", "))
+	section("OUTPUT")
+	fmt.Fprintf(&s, "    %s\n", kinds(pkg.Output, ", "))
+
+	if len(pkg.Examples) > 0 {
+		section("EXAMPLES")
+		for i, e := range pkg.Examples {
+			if i > 0 {
+				s.WriteString("\n")
+			}
+			if e.Description != "" {
+				fmt.Fprintf(&s, "    %s\n", e.Description)
+			}
+			fmt.Fprintf(&s, "        pipe '%s'\n", e.Script)
+		}
+	}
+
+	return strings.TrimRight(s.String(), "\n")
+}
@@ -0,0 +1,44 @@
+package pipe
+
+import (
+	"github.com/relvacode/pipe/console"
+	"strings"
+	"testing"
+)
+
+func TestHelp(t *testing.T) {
+	pkg := Pkg{
+		Name:        "test",
+		Description: "Test a pipe",
+		Output:      []Kind{String, Number},
+		Examples: []Example{
+			{
+				Description: "An example",
+				Script:      "test -n 1 a",
+			},
+		},
+		Constructor: func(cmd *console.Command) Pipe {
+			cmd.Option("n").Default(0).Describe("A number").Int()
+			cmd.Arg(0).Describe("A value").String()
+			return nil
+		},
+	}
+
+	help := Help(pkg)
+	for _, expect := range []string{
+		"NAME\n    test - Test a pipe\n",
+		"        A number\n",
+		"        A value\n",
+		"INPUT\n    any\n",
+		"OUTPUT\n    string, number\n",
+		"EXAMPLES\n    An example\n        pipe 'test -n 1 a'",
+	} {
+		if !strings.Contains(help, expect) {
+			t.Fatalf("expected help to contain %q but got\n%s", expect, help)
+		}
+	}
+
+	if s := Summary(pkg); !strings.HasPrefix(s, "test\t") || !strings.HasSuffix(s, "\tTest a pipe") {
+		t.Fatalf("unexpected summary %q", s)
+	}
+}
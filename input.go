@@ -0,0 +1,123 @@
+package pipe
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+// maxPart is the size of the largest part that Framing can split from its input
+const maxPart = 64 << 20
+
+// Framing is how a source splits what it reads into frames
+type Framing struct {
+	// Separator splits the input into a frame for each part separated by it.
+	// If empty then the input is written as a single reader, or decoded as a sequence of JSON values if JSON is set.
+	Separator string
+	// JSON decodes each part as JSON instead of writing it as a string
+	JSON bool
+}
+
+// Framed returns true if the input is split into more than a single reader
+func (fr Framing) Framed() bool {
+	return fr.Separator != "" || fr.JSON
+}
+
+// splitAt splits data at each occurrence of sep
+func splitAt(sep string) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := strings.Index(string(data), sep); i >= 0 {
+			return i + len(sep), data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// part converts a part of the input into the object written for it
+func (fr Framing) part(s string) (interface{}, bool, error) {
+	if fr.Separator == "\n" {
+		s = strings.TrimSuffix(s, "\r")
+	}
+	if !fr.JSON {
+		return s, true, nil
+	}
+	if strings.TrimSpace(s) == "" {
+		return nil, false, nil
+	}
+	var x interface{}
+	err := json.Unmarshal([]byte(s), &x)
+	return x, true, err
+}
+
+// decode writes each JSON value read from r
+func decode(ctx context.Context, r io.Reader, stream Stream) error {
+	d := json.NewDecoder(r)
+	for i := 1; ; i++ {
+		var x interface{}
+		err := d.Decode(&x)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "value %d", i)
+		}
+		if drained(ctx) {
+			return nil
+		}
+		err = stream.Write(nil, x)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// write writes r to stream, split into frames.
+// It stops without error if the pipeline is drained.
+func (fr Framing) write(ctx context.Context, r io.Reader, stream Stream) error {
+	if !fr.Framed() {
+		return stream.Write(nil, r)
+	}
+	if fr.Separator == "" {
+		return decode(ctx, r, stream)
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxPart)
+	s.Split(splitAt(fr.Separator))
+	for i := 1; s.Scan(); i++ {
+		x, ok, err := fr.part(s.Text())
+		if err != nil {
+			return errors.Wrapf(err, "part %d", i)
+		}
+		if !ok {
+			continue
+		}
+		if drained(ctx) {
+			return nil
+		}
+		err = stream.Write(nil, x)
+		if err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// drained returns true if the pipeline running with ctx has been drained
+func drained(ctx context.Context) bool {
+	select {
+	case <-Drained(ctx):
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,151 @@
+package pipe
+
+import (
+	"context"
+	"github.com/relvacode/pipe/tap"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// framingPipe writes its reader split by its framing
+type framingPipe struct {
+	r       io.Reader
+	framing Framing
+}
+
+func (p framingPipe) Go(ctx context.Context, stream Stream) error {
+	return p.framing.write(ctx, p.r, stream)
+}
+
+// objects runs source and returns the objects it writes
+func objects(t *testing.T, source Pipe) []interface{} {
+	var result = new(collectPipe)
+	err := Run(context.Background(), []Runnable{
+		{Pipe: source},
+		{Pipe: result},
+	}).ErrorOrNil()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objects = make([]interface{}, len(result.Frames))
+	for i, f := range result.Frames {
+		objects[i] = f.Object
+	}
+	return objects
+}
+
+func TestFraming(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Input   string
+		Framing Framing
+		Expect  []interface{}
+	}{
+		{
+			Name:    "lines",
+			Input:   "a\r\nb\n\nc\n",
+			Framing: Framing{Separator: "\n"},
+			Expect:  []interface{}{"a", "b", "", "c"},
+		},
+		{
+			Name:    "null",
+			Input:   "a b\x00c\nd\x00",
+			Framing: Framing{Separator: "\x00"},
+			Expect:  []interface{}{"a b", "c\nd"},
+		},
+		{
+			Name:    "ndjson",
+			Input:   "{\"a\": 1}\n\n[true]\n\"c\"",
+			Framing: Framing{Separator: "\n", JSON: true},
+			Expect:  []interface{}{map[string]interface{}{"a": float64(1)}, []interface{}{true}, "c"},
+		},
+		{
+			Name:    "json stream",
+			Input:   "{\"a\":\n 1} 2",
+			Framing: Framing{JSON: true},
+			Expect:  []interface{}{map[string]interface{}{"a": float64(1)}, float64(2)},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			got := objects(t, framingPipe{r: strings.NewReader(tc.Input), framing: tc.Framing})
+			if !reflect.DeepEqual(got, tc.Expect) {
+				t.Fatalf("expected %#v but got %#v", tc.Expect, got)
+			}
+		})
+	}
+}
+
+func TestFraming_Whole(t *testing.T) {
+	r := strings.NewReader("a\nb")
+	got := objects(t, framingPipe{r: r})
+	if len(got) != 1 || got[0] != r {
+		t.Fatalf("expected the reader to be written as a single frame but got %#v", got)
+	}
+}
+
+func TestFraming_Error(t *testing.T) {
+	err := Run(context.Background(), []Runnable{
+		{Pipe: framingPipe{r: strings.NewReader("1\n{\n"), framing: Framing{Separator: "\n", JSON: true}}},
+		{Pipe: new(collectPipe)},
+	}).ErrorOrNil()
+	if err == nil || !strings.Contains(err.Error(), "part 2") {
+		t.Fatalf("expected an error decoding part 2 but got %v", err)
+	}
+}
+
+func TestFilePipe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var paths = []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}
+	for i, p := range paths {
+		err := ioutil.WriteFile(p, []byte(strings.Repeat(p+"\n", i+1)), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("files", func(t *testing.T) {
+		got := objects(t, FilePipe{Paths: paths})
+		if len(got) != 2 {
+			t.Fatalf("expected 2 files but got %d", len(got))
+		}
+		for i, x := range got {
+			if f, ok := x.(*tap.File); !ok || f.Path != paths[i] {
+				t.Fatalf("expected file %s but got %#v", paths[i], x)
+			}
+		}
+	})
+	t.Run("lines", func(t *testing.T) {
+		got := objects(t, FilePipe{Paths: paths, Framing: Framing{Separator: "\n"}})
+		expect := []interface{}{paths[0], paths[1], paths[1]}
+		if !reflect.DeepEqual(got, expect) {
+			t.Fatalf("expected %v but got %v", expect, got)
+		}
+	})
+	t.Run("missing", func(t *testing.T) {
+		err := Run(context.Background(), []Runnable{
+			{Pipe: FilePipe{Paths: []string{filepath.Join(dir, "c")}}},
+			{Pipe: new(collectPipe)},
+		}).ErrorOrNil()
+		if err == nil {
+			t.Fatal("expected an error opening a missing file")
+		}
+	})
+}
+
+func TestEmptyPipe(t *testing.T) {
+	got := objects(t, EmptyPipe{})
+	if len(got) != 1 || got[0] != nil {
+		t.Fatalf("expected a single empty frame but got %#v", got)
+	}
+}
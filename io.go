@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/tap"
 	"github.com/sirupsen/logrus"
 	"io"
 	"sync/atomic"
@@ -34,22 +35,34 @@ func init() {
 	streamIds = &ids
 }
 
+// DefaultBuffer is the number of frames that can be written to a stream before the writer blocks,
+// used by any pipe that does not set its own buffer.
+var DefaultBuffer int
+
 func NewStream(ctx context.Context, tag *Tag) *stream {
+	return newStream(ctx, tag, 0, new(Stats))
+}
+
+// newStream creates a stream that buffers up to buffer frames written to it
+func newStream(ctx context.Context, tag *Tag, buffer int, stats *Stats) *stream {
 	return &stream{
 		id:    atomic.AddUint64(streamIds, 1),
 		ctx:   ctx,
+		scope: tap.ScopeOf(ctx),
 		tag:   tag,
+		stats: stats,
 		ok:    make(chan struct{}),
-		input: make(chan *DataFrame),
+		input: make(chan *DataFrame, buffer),
 	}
 }
 
 type stream struct {
-	id   uint64
-	ctx  context.Context
-	tag  *Tag
-	f    *DataFrame
-	r, w uint64
+	id    uint64
+	ctx   context.Context
+	scope *tap.Scope
+	tag   *Tag
+	f     *DataFrame
+	stats *Stats // shared by all copies of this stream
 
 	input chan *DataFrame
 	ok    chan struct{} // closed when downstream is closed
@@ -59,7 +72,7 @@ type stream struct {
 }
 
 func (s *stream) Close() {
-	logrus.Debugf("%s terminated read:%d write:%d", s, s.r, s.w)
+	logrus.Debugf("%s terminated %s", s, s.stats)
 	if s.up != nil {
 		close(s.up.ok)
 	}
@@ -80,15 +93,27 @@ func (s *stream) Down(n *stream) {
 	s.down = n
 }
 
+func (s *stream) read(x *DataFrame, ok bool) (*DataFrame, error) {
+	if !ok {
+		return nil, io.EOF
+	}
+	s.f = x
+	atomic.AddUint64(&s.stats.read, 1)
+	return x, nil
+}
+
 func (s *stream) Read(cancel <-chan struct{}) (*DataFrame, error) {
 	select {
 	case x, ok := <-s.input:
-		if !ok {
-			return nil, io.EOF
-		}
-		s.f = x
-		s.r++
-		return x, nil
+		return s.read(x, ok)
+	default:
+	}
+
+	start := s.stats.block(ReadBlocked)
+	defer s.stats.unblock(start, &s.stats.readBlocked)
+	select {
+	case x, ok := <-s.input:
+		return s.read(x, ok)
 	case <-s.ctx.Done():
 		return nil, s.ctx.Err()
 	case <-cancel:
@@ -103,10 +128,20 @@ func (s *stream) Write(cancel <-chan struct{}, obj interface{}) error {
 	} else {
 		f = s.f.Copy(obj, s.tag)
 	}
-	f.Index = s.w
+	f.scope = s.scope
+	f.Index = atomic.LoadUint64(&s.stats.written)
+	select {
+	case s.down.input <- f:
+		atomic.AddUint64(&s.stats.written, 1)
+		return nil
+	default:
+	}
+
+	start := s.stats.block(WriteBlocked)
+	defer s.stats.unblock(start, &s.stats.writeBlocked)
 	select {
 	case s.down.input <- f:
-		s.w++
+		atomic.AddUint64(&s.stats.written, 1)
 		return nil
 	case <-s.ok:
 		return io.EOF
@@ -119,10 +154,12 @@ func (s *stream) Write(cancel <-chan struct{}, obj interface{}) error {
 
 func (s *stream) With(f *DataFrame) Stream {
 	return &stream{
-		id:  atomic.AddUint64(streamIds, 1),
-		ctx: s.ctx,
-		tag: s.tag,
-		f:   f,
+		id:    atomic.AddUint64(streamIds, 1),
+		ctx:   s.ctx,
+		scope: s.scope,
+		tag:   s.tag,
+		f:     f,
+		stats: s.stats,
 
 		input: s.input,
 		ok:    s.ok,
@@ -0,0 +1,53 @@
+package pipe
+
+import (
+	"context"
+	"testing"
+)
+
+// waitPipe waits until done is closed before reading all of its input
+type waitPipe struct {
+	done <-chan struct{}
+}
+
+func (p waitPipe) Go(ctx context.Context, stream Stream) error {
+	<-p.done
+	for {
+		_, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// signalPipe runs a pipe then closes done
+type signalPipe struct {
+	Pipe
+	done chan<- struct{}
+}
+
+func (p signalPipe) Go(ctx context.Context, stream Stream) error {
+	defer close(p.done)
+	return p.Pipe.Go(ctx, stream)
+}
+
+func TestStream_Buffer(t *testing.T) {
+	var (
+		done   = make(chan struct{})
+		source = &Stats{}
+		sink   = &Stats{}
+	)
+	err := Run(context.Background(), []Runnable{
+		{Pipe: signalPipe{Pipe: sliceSourcePipe{0, 1, 2, 3, 4}, done: done}, Stats: source},
+		{Pipe: waitPipe{done: done}, Buffer: 5, Stats: sink},
+	}).ErrorOrNil()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Written() != 5 {
+		t.Fatalf("expected 5 writes but got %d", source.Written())
+	}
+	if sink.Read() != 5 {
+		t.Fatalf("expected 5 reads but got %d", sink.Read())
+	}
+}
@@ -0,0 +1,54 @@
+package pipe
+
+import (
+	"context"
+	"github.com/relvacode/pipe/tap"
+	"sync"
+)
+
+// MergePipe runs each of its branches as a source
+// and writes all objects produced by every branch as soon as they are produced.
+// Each branch is given a single empty frame as input.
+// The input to the MergePipe is not read.
+// The MergePipe completes once all of its branches have completed.
+type MergePipe struct {
+	Branches []Branch
+}
+
+func (p *MergePipe) Go(ctx context.Context, stream Stream) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		out  = &syncStream{Stream: stream, mtx: new(sync.Mutex)}
+		errs = make(chan error, len(p.Branches))
+	)
+
+	for i := range p.Branches {
+		input := make(chan *DataFrame, 1)
+		f := NewDataFrame(nil, nil)
+		f.scope = tap.ScopeOf(ctx)
+		input <- f
+		close(input)
+
+		go func(sub SubPipe) {
+			err := sub.Go(ctx, &branchStream{
+				ctx:   ctx,
+				input: input,
+				out:   out,
+			})
+			if err != nil {
+				cancel()
+			}
+			errs <- err
+		}(p.Branches[i].SubPipe())
+	}
+
+	var failed RuntimeError
+	for range p.Branches {
+		if err := <-errs; err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed.ErrorOrNil()
+}
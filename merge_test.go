@@ -0,0 +1,49 @@
+package pipe
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMergePipe(t *testing.T) {
+	var (
+		result = new(collectPipe)
+		merge  = &MergePipe{
+			Branches: []Branch{
+				{Tag: NewTag("a"), Pipes: []Runnable{{Pipe: sliceSourcePipe{"a1", "a2"}}}},
+				{Tag: NewTag("b"), Pipes: []Runnable{{Pipe: sliceSourcePipe{"b1", "b2", "b3"}}}},
+			},
+		}
+	)
+	err := Run(context.Background(), []Runnable{
+		{Pipe: sliceSourcePipe{"ignored"}},
+		{Pipe: merge, Tag: NewTag("m")},
+		{Pipe: result},
+	}).ErrorOrNil()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for i, f := range result.Frames {
+		if f.Index != uint64(i) {
+			t.Fatalf("expected frame %d to have index %d but got %d", i, i, f.Index)
+		}
+		for _, origin := range []string{"a", "b"} {
+			if v, ok := f.Stack[origin]; ok {
+				if !strings.HasPrefix(v.(string), origin) {
+					t.Fatalf("expected %v to be tagged as %q", v, origin)
+				}
+				got = append(got, v.(string))
+			}
+		}
+	}
+	sort.Strings(got)
+
+	var want = "a1,a2,b1,b2,b3"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("expected %v but got %v", want, got)
+	}
+}
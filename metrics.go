@@ -0,0 +1,98 @@
+package pipe
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// metric is a single metric family in the Prometheus text format
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	value func(*Stats) float64
+}
+
+var metrics = []metric{
+	{
+		name:  "pipe_frames_read_total",
+		help:  "Number of frames read by the pipe",
+		kind:  "counter",
+		value: func(s *Stats) float64 { return float64(s.Read()) },
+	},
+	{
+		name:  "pipe_frames_written_total",
+		help:  "Number of objects written by the pipe",
+		kind:  "counter",
+		value: func(s *Stats) float64 { return float64(s.Written()) },
+	},
+	{
+		name:  "pipe_errors_total",
+		help:  "Number of errors returned by the pipe, including those handled by an error policy",
+		kind:  "counter",
+		value: func(s *Stats) float64 { return float64(s.Errors()) },
+	},
+	{
+		name:  "pipe_read_blocked_seconds_total",
+		help:  "Time the pipe spent waiting for a frame to read",
+		kind:  "counter",
+		value: func(s *Stats) float64 { return s.ReadBlocked().Seconds() },
+	},
+	{
+		name:  "pipe_write_blocked_seconds_total",
+		help:  "Time the pipe spent waiting for the next pipe to accept a write",
+		kind:  "counter",
+		value: func(s *Stats) float64 { return s.WriteBlocked().Seconds() },
+	},
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats the labels that identify a pipe
+func labels(i int, r Runnable, extra ...string) string {
+	var l = []string{
+		fmt.Sprintf("stage=\"%d\"", i),
+		fmt.Sprintf("pipe=\"%s\"", labelEscaper.Replace(fmt.Sprintf("%T", r.Pipe))),
+		fmt.Sprintf("tag=\"%s\"", labelEscaper.Replace(r.Tag.String())),
+	}
+	for j := 0; j+1 < len(extra); j += 2 {
+		l = append(l, fmt.Sprintf("%s=\"%s\"", extra[j], labelEscaper.Replace(extra[j+1])))
+	}
+	return "{" + strings.Join(l, ",") + "}"
+}
+
+// MetricsHandler serves the statistics of each pipe in the Prometheus text exposition format.
+// Pipes without Stats are not included.
+func MetricsHandler(runnables []Runnable) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w := bufio.NewWriter(rw)
+		defer w.Flush()
+
+		for _, m := range metrics {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+			for i, r := range runnables {
+				if r.Stats != nil {
+					fmt.Fprintf(w, "%s%s %g\n", m.name, labels(i, r), m.value(r.Stats))
+				}
+			}
+		}
+
+		fmt.Fprint(w, "# HELP pipe_state Whether the pipe is currently in this state\n# TYPE pipe_state gauge\n")
+		for i, r := range runnables {
+			if r.Stats == nil {
+				continue
+			}
+			current := r.Stats.State()
+			for _, state := range States {
+				var v int
+				if state == current {
+					v = 1
+				}
+				fmt.Fprintf(w, "pipe_state%s %d\n", labels(i, r, "state", state.String()), v)
+			}
+		}
+	})
+}
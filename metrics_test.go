@@ -0,0 +1,51 @@
+package pipe
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	var (
+		tag     = NewTag("x")
+		modules = []Runnable{
+			{Pipe: sliceSourcePipe{0, 1, 2}, Tag: tag, Stats: new(Stats)},
+			{Pipe: new(collectPipe), Stats: new(Stats)},
+		}
+		server = httptest.NewServer(MetricsHandler(modules))
+	)
+	defer server.Close()
+
+	err := Run(context.Background(), modules).ErrorOrNil()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body = string(b)
+	for _, line := range []string{
+		"# TYPE pipe_frames_read_total counter",
+		`pipe_frames_written_total{stage="0",pipe="pipe.sliceSourcePipe",tag="x"} 3`,
+		`pipe_frames_read_total{stage="1",pipe="*pipe.collectPipe",tag="<none>"} 3`,
+		`pipe_errors_total{stage="1",pipe="*pipe.collectPipe",tag="<none>"} 0`,
+		`pipe_state{stage="0",pipe="pipe.sliceSourcePipe",tag="x",state="done"} 1`,
+		`pipe_state{stage="0",pipe="pipe.sliceSourcePipe",tag="x",state="running"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expected metrics to contain %q but got\n%s", line, body)
+		}
+	}
+}
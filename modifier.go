@@ -0,0 +1,154 @@
+package pipe
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"time"
+)
+
+// restarts returns true if modifiers give a pipe an error policy that restarts it after it fails
+func restarts(modifiers map[string]string) bool {
+	if v, ok := modifiers["errors"]; ok && OnError(v) != Fail {
+		return true
+	}
+	if v, ok := modifiers["retries"]; ok && v != "0" {
+		return true
+	}
+	_, ok := modifiers["deadletter"]
+	return ok
+}
+
+// A Constructor creates a new instance of a pipe
+type Constructor func() (Pipe, error)
+
+// Modify creates the runnable pipe for a command using the modifiers given to that command in the pipe script.
+//
+//	buffer=<n>           buffer up to n frames written to the pipe, overriding DefaultBuffer
+//	jobs=<n>             run n copies of the pipe concurrently
+//	unordered            with jobs, write objects as soon as they are produced instead of in input order
+//	errors=<policy>      what to do when the pipe fails to process a frame: fail, skip, retry or dead
+//	retries=<n>          retry a failed frame n times before applying the error policy
+//	backoff=<duration>   wait before the first retry, doubling after each attempt
+//	deadletter=<pipe>    send failed frames to this pipe, usually an alias
+//	persistent[=<d>]     run a program once for all frames instead of once for each, separating each frame by d or a new line
+//
+// The script only reads with as the start of the modifiers when it is followed by one of dsl.ModifierKeys,
+// so each modifier here must also be listed there.
+func Modify(create Constructor, modifiers map[string]string, reg *Registry) (Runnable, error) {
+	var (
+		rn        Runnable
+		jobs      = 1
+		unordered bool
+		policy    = Policy{
+			OnError: Fail,
+			Retries: -1,
+			Backoff: time.Second,
+		}
+		deadletter string
+		persistent bool
+		delimiter  = "\n"
+	)
+	for k, v := range modifiers {
+		switch k {
+		case "buffer":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return rn, errors.Errorf("modifier buffer: expected a number but got %q", v)
+			}
+			rn.Buffer = n
+			if n == 0 {
+				rn.Buffer = -1
+			}
+		case "jobs":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return rn, errors.Errorf("modifier jobs: expected a positive number but got %q", v)
+			}
+			jobs = n
+		case "unordered":
+			unordered = true
+		case "errors":
+			switch OnError(v) {
+			case Fail, Skip, Retry, Dead:
+				policy.OnError = OnError(v)
+			default:
+				return rn, errors.Errorf("modifier errors: expected one of fail, skip, retry or dead but got %q", v)
+			}
+		case "retries":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return rn, errors.Errorf("modifier retries: expected a number but got %q", v)
+			}
+			policy.Retries = n
+		case "backoff":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return rn, errors.Wrap(err, "modifier backoff")
+			}
+			policy.Backoff = d
+		case "deadletter":
+			deadletter = v
+		case "persistent":
+			persistent = true
+			if v == "" {
+				break
+			}
+			d, err := strconv.Unquote(`"` + v + `"`)
+			if err != nil || d == "" {
+				return rn, errors.Errorf("modifier persistent: expected a delimiter such as \\t or \\x00 but got %q", v)
+			}
+			delimiter = d
+		default:
+			return rn, errors.Errorf("unknown modifier %q", k)
+		}
+	}
+
+	if deadletter != "" {
+		if policy.OnError != Fail && policy.OnError != Dead {
+			return rn, errors.Errorf("modifier deadletter cannot be used with errors=%s", policy.OnError)
+		}
+		policy.OnError = Dead
+		if _, ok := reg.Lookup(deadletter); !ok {
+			return rn, errors.Errorf("modifier deadletter: unknown pipe %q", deadletter)
+		}
+		policy.DeadLetter = func() (Pipe, error) {
+			return Make(deadletter, "", reg)
+		}
+	}
+	if policy.OnError == Dead && policy.DeadLetter == nil {
+		return rn, errors.New("errors=dead requires a pipe given by deadletter=<pipe>")
+	}
+	if policy.Retries < 0 {
+		policy.Retries = 0
+		if policy.OnError == Retry {
+			policy.Retries = 3
+		}
+	}
+
+	if persistent {
+		create = coprocess(create, delimiter)
+	}
+	if policy.OnError != Fail || policy.Retries > 0 {
+		create = policy.constructor(create)
+	}
+
+	if jobs == 1 {
+		p, err := create()
+		rn.Pipe = p
+		return rn, err
+	}
+
+	var pipes = make([]Pipe, jobs)
+	for i := range pipes {
+		p, err := create()
+		if err != nil {
+			return rn, err
+		}
+		pipes[i] = p
+	}
+	rn.Pipe = &ParallelPipe{
+		Pipes:   pipes,
+		Ordered: !unordered,
+	}
+	return rn, nil
+}
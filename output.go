@@ -0,0 +1,387 @@
+package pipe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/tap"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output is how EchoPipe writes each object
+type Output string
+
+const (
+	// Raw writes the contents of readers and the default format of any other object, separated by a new line
+	Raw Output = "raw"
+	// JSON writes all objects as one indented JSON array
+	JSON Output = "json"
+	// NDJSON writes each object as compact JSON followed by a new line
+	NDJSON Output = "ndjson"
+	// YAML writes each object as a YAML document
+	YAML Output = "yaml"
+	// Table writes all objects as the rows of a table, with a column for each key or field
+	Table Output = "table"
+)
+
+// Outputs are all of the ways EchoPipe can write objects
+var Outputs = []Output{Raw, JSON, NDJSON, YAML, Table}
+
+// record is an object with named values in the order they should be written
+type record struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (r *record) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, k := range r.keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(r.values[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteString(":")
+		b.Write(value)
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+func (r *record) MarshalYAML() (interface{}, error) {
+	var m = make(yaml.MapSlice, len(r.keys))
+	for i, k := range r.keys {
+		m[i] = yaml.MapItem{Key: k, Value: r.values[k]}
+	}
+	return m, nil
+}
+
+// exported returns the exported fields of the struct type t
+func exported(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.PkgPath == "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// normalize converts x into an object that can be written as JSON, YAML or a table.
+// Structs and maps become records, readers that aren't also structs are read as text.
+func normalize(x interface{}) (interface{}, error) {
+	return normalizeValue(x, true)
+}
+
+// normalizeValue is normalize where readers that aren't also structs are left unread and become nil if read is false
+func normalizeValue(x interface{}, read bool) (interface{}, error) {
+	switch v := x.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return string(v), nil
+	case json.Marshaler:
+		return v, nil
+	}
+
+	var rv = reflect.Indirect(reflect.ValueOf(x))
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if rv.Kind() == reflect.Struct && len(exported(rv.Type())) > 0 {
+		return normalizeStruct(rv, read)
+	}
+
+	if r, ok := x.(io.Reader); ok {
+		if !read {
+			return nil, nil
+		}
+		b, err := ioutil.ReadAll(r)
+		_ = tap.Close(r)
+		return string(b), err
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		return normalizeMap(rv, read)
+	case reflect.Slice, reflect.Array:
+		var list = make([]interface{}, rv.Len())
+		for i := range list {
+			item, err := normalizeValue(rv.Index(i).Interface(), read)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = item
+		}
+		return list, nil
+	case reflect.Struct:
+		return fmt.Sprint(x), nil
+	}
+	return rv.Interface(), nil
+}
+
+func normalizeStruct(rv reflect.Value, read bool) (*record, error) {
+	var (
+		fields = exported(rv.Type())
+		r      = &record{
+			keys:   make([]string, len(fields)),
+			values: make(map[string]interface{}, len(fields)),
+		}
+	)
+	for i, f := range fields {
+		v, err := normalizeValue(rv.FieldByIndex(f.Index).Interface(), read)
+		if err != nil {
+			return nil, errors.Wrap(err, f.Name)
+		}
+		r.keys[i] = f.Name
+		r.values[f.Name] = v
+	}
+	return r, nil
+}
+
+func normalizeMap(rv reflect.Value, read bool) (*record, error) {
+	var r = &record{
+		values: make(map[string]interface{}, rv.Len()),
+	}
+	for _, k := range rv.MapKeys() {
+		key := fmt.Sprint(k.Interface())
+		v, err := normalizeValue(rv.MapIndex(k).Interface(), read)
+		if err != nil {
+			return nil, errors.Wrap(err, key)
+		}
+		r.keys = append(r.keys, key)
+		r.values[key] = v
+	}
+	sort.Strings(r.keys)
+	return r, nil
+}
+
+// An encoder writes each object to w in some output
+type encoder interface {
+	encode(w io.Writer, i int, x interface{}) error
+	// end is called after all objects are encoded
+	end(w io.Writer, n int) error
+}
+
+// rawEncoder writes objects in their default format
+type rawEncoder struct {
+	separator string
+}
+
+func (e rawEncoder) encode(w io.Writer, i int, x interface{}) error {
+	if i > 0 {
+		if _, err := io.WriteString(w, e.separator); err != nil {
+			return err
+		}
+	}
+	switch o := x.(type) {
+	case io.Reader:
+		_, err := io.Copy(w, o)
+		_ = tap.Close(o)
+		return err
+	default:
+		_, err := fmt.Fprint(w, x)
+		return err
+	}
+}
+
+func (rawEncoder) end(io.Writer, int) error {
+	return nil
+}
+
+// jsonEncoder writes objects as an indented JSON array
+type jsonEncoder struct{}
+
+func (jsonEncoder) encode(w io.Writer, i int, x interface{}) error {
+	v, err := normalize(x)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	if i == 0 {
+		_, err = io.WriteString(w, "[\n  ")
+	} else {
+		_, err = io.WriteString(w, ",\n  ")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (jsonEncoder) end(w io.Writer, n int) error {
+	if n == 0 {
+		_, err := io.WriteString(w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(w, "\n]\n")
+	return err
+}
+
+// ndjsonEncoder writes each object as compact JSON followed by a separator
+type ndjsonEncoder struct {
+	separator string
+}
+
+func (e ndjsonEncoder) encode(w io.Writer, i int, x interface{}) error {
+	v, err := normalize(x)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s", b, e.separator)
+	return err
+}
+
+func (ndjsonEncoder) end(io.Writer, int) error {
+	return nil
+}
+
+// yamlEncoder writes each object as a YAML document
+type yamlEncoder struct{}
+
+func (yamlEncoder) encode(w io.Writer, i int, x interface{}) error {
+	v, err := normalize(x)
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "---\n%s", b)
+	return err
+}
+
+func (yamlEncoder) end(io.Writer, int) error {
+	return nil
+}
+
+// tableEncoder collects all objects and writes them as an aligned table once they have all been read.
+// The columns are the keys of every record in the order they are first seen.
+// Objects that aren't records are written in a column named value.
+type tableEncoder struct {
+	columns []string
+	seen    map[string]bool
+	rows    []*record
+}
+
+const valueColumn = "value"
+
+func (e *tableEncoder) column(k string) {
+	if e.seen == nil {
+		e.seen = make(map[string]bool)
+	}
+	if !e.seen[k] {
+		e.seen[k] = true
+		e.columns = append(e.columns, k)
+	}
+}
+
+func (e *tableEncoder) encode(w io.Writer, i int, x interface{}) error {
+	v, err := normalize(x)
+	if err != nil {
+		return err
+	}
+	r, ok := v.(*record)
+	if !ok {
+		r = &record{
+			keys:   []string{valueColumn},
+			values: map[string]interface{}{valueColumn: v},
+		}
+	}
+	for _, k := range r.keys {
+		e.column(k)
+	}
+	e.rows = append(e.rows, r)
+	return nil
+}
+
+// cells replaces characters that would break the alignment of a table
+var cells = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// cell formats a value of a table
+func cell(x interface{}) string {
+	switch x.(type) {
+	case nil:
+		return ""
+	case *record, []interface{}:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return fmt.Sprint(x)
+		}
+		return string(b)
+	}
+	return cells.Replace(fmt.Sprint(x))
+}
+
+func (e *tableEncoder) end(w io.Writer, n int) error {
+	if n == 0 {
+		return nil
+	}
+	var (
+		b bytes.Buffer
+		t = tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	)
+	fmt.Fprintln(t, strings.Join(e.columns, "\t"))
+	for _, r := range e.rows {
+		var row = make([]string, len(e.columns))
+		for i, k := range e.columns {
+			row[i] = cell(r.values[k])
+		}
+		fmt.Fprintln(t, strings.Join(row, "\t"))
+	}
+	if err := t.Flush(); err != nil {
+		return err
+	}
+
+	// Empty cells at the end of a row are still padded
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newEncoder creates the encoder of an output, where separator is used by Raw and NDJSON
+func newEncoder(output Output, separator string) (encoder, error) {
+	switch output {
+	case Raw, "":
+		if separator == "" {
+			separator = "\n"
+		}
+		return rawEncoder{separator: separator}, nil
+	case JSON:
+		return jsonEncoder{}, nil
+	case NDJSON:
+		if separator == "" {
+			separator = "\n"
+		}
+		return ndjsonEncoder{separator: separator}, nil
+	case YAML:
+		return yamlEncoder{}, nil
+	case Table:
+		return &tableEncoder{}, nil
+	}
+	return nil, errors.Errorf("unknown output %q", output)
+}
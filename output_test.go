@@ -0,0 +1,108 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type outputTestObject struct {
+	Name string
+	Size int
+	Body *bytes.Buffer
+
+	hidden bool
+}
+
+// echo writes each object to an EchoPipe and returns what it wrote
+func echo(t *testing.T, p *EchoPipe, objects ...interface{}) string {
+	var b bytes.Buffer
+	p.Writer = &b
+	err := Run(context.Background(), []Runnable{
+		{Pipe: sliceSourcePipe(objects)},
+		{Pipe: p},
+	}).ErrorOrNil()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestEchoPipe(t *testing.T) {
+	var objects = func() []interface{} {
+		return []interface{}{
+			&outputTestObject{Name: "a", Size: 1, Body: bytes.NewBufferString("x\ty")},
+			map[interface{}]interface{}{"Name": "b", "Extra": []interface{}{1, "2"}},
+			"c",
+		}
+	}
+	cases := []struct {
+		Echo   EchoPipe
+		Expect string
+	}{
+		{
+			Echo:   EchoPipe{},
+			Expect: "&{a 1 x\ty false}\nmap[Extra:[1 2] Name:b]\nc",
+		},
+		{
+			Echo:   EchoPipe{Output: Raw, Separator: "\x00"},
+			Expect: "&{a 1 x\ty false}\x00map[Extra:[1 2] Name:b]\x00c",
+		},
+		{
+			Echo:   EchoPipe{Output: NDJSON},
+			Expect: `{"Name":"a","Size":1,"Body":"x\ty"}` + "\n" + `{"Extra":[1,"2"],"Name":"b"}` + "\n" + `"c"` + "\n",
+		},
+		{
+			Echo:   EchoPipe{Output: JSON},
+			Expect: "[\n  {\n    \"Name\": \"a\",\n    \"Size\": 1,\n    \"Body\": \"x\\ty\"\n  },\n  {\n    \"Extra\": [\n      1,\n      \"2\"\n    ],\n    \"Name\": \"b\"\n  },\n  \"c\"\n]\n",
+		},
+		{
+			Echo:   EchoPipe{Output: YAML},
+			Expect: "---\nName: a\nSize: 1\nBody: \"x\\ty\"\n---\nExtra:\n- 1\n- \"2\"\nName: b\n---\nc\n",
+		},
+		{
+			Echo: EchoPipe{Output: Table},
+			Expect: "Name  Size  Body  Extra    value\n" +
+				"a     1     x\\ty\n" +
+				"b                 [1,\"2\"]\n" +
+				"                           c\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.Echo.Output), func(t *testing.T) {
+			if got := echo(t, &tc.Echo, objects()...); got != tc.Expect {
+				t.Fatalf("Wanted\n%q\nbut got\n%q", tc.Expect, got)
+			}
+		})
+	}
+}
+
+func TestEchoPipe_Empty(t *testing.T) {
+	if got := echo(t, &EchoPipe{Output: JSON}); got != "[]\n" {
+		t.Fatalf("expected an empty array but got %q", got)
+	}
+	if got := echo(t, &EchoPipe{Output: Table}); got != "" {
+		t.Fatalf("expected no table but got %q", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	x, err := normalize(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x != now {
+		t.Fatalf("expected time to be kept but got %v", x)
+	}
+
+	x, err = normalize(strings.NewReader("text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x != "text" {
+		t.Fatalf("expected a reader to be read as text but got %v", x)
+	}
+}
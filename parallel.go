@@ -0,0 +1,277 @@
+package pipe
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"sync"
+)
+
+// job is a frame read from the input stream of a ParallelPipe
+type job struct {
+	seq   uint64
+	frame *DataFrame
+}
+
+// output is an object written by a worker of a ParallelPipe.
+type output struct {
+	seq      uint64
+	frame    *DataFrame
+	obj      interface{}
+	done     bool // the worker has finished the job with this sequence
+	trailing bool // the object was written after the worker read its last job
+}
+
+// worker is the state shared by all streams given to one copy of a pipe
+type worker struct {
+	ctx  context.Context
+	jobs <-chan *job
+	out  chan<- *output
+	job  *job
+}
+
+func (w *worker) send(cancel <-chan struct{}, o *output) error {
+	select {
+	case w.out <- o:
+		return nil
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	case <-cancel:
+		return ErrIOCancelled
+	}
+}
+
+// finish marks the current job of this worker as done
+func (w *worker) finish(cancel <-chan struct{}) error {
+	if w.job == nil {
+		return nil
+	}
+	err := w.send(cancel, &output{seq: w.job.seq, done: true})
+	w.job = nil
+	return err
+}
+
+// workerStream is the Stream given to each copy of a pipe in a ParallelPipe
+type workerStream struct {
+	*worker
+	f    *DataFrame
+	with bool
+}
+
+func (s *workerStream) Read(cancel <-chan struct{}) (*DataFrame, error) {
+	err := s.finish(cancel)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case j, ok := <-s.jobs:
+		if !ok {
+			return nil, io.EOF
+		}
+		s.job = j
+		return j.frame, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	case <-cancel:
+		return nil, ErrIOCancelled
+	}
+}
+
+func (s *workerStream) Write(cancel <-chan struct{}, obj interface{}) error {
+	var o = &output{
+		obj:   obj,
+		frame: s.f,
+	}
+	if s.job == nil {
+		o.trailing = true
+	} else {
+		o.seq = s.job.seq
+		if !s.with {
+			o.frame = s.job.frame
+		}
+	}
+	return s.send(cancel, o)
+}
+
+func (s *workerStream) With(f *DataFrame) Stream {
+	return &workerStream{
+		worker: s.worker,
+		f:      f,
+		with:   true,
+	}
+}
+
+// pending holds the outputs of a job that cannot be written yet
+type pending struct {
+	outputs []*output
+	done    bool
+}
+
+// emitter writes the outputs of all workers to the stream of a ParallelPipe.
+// If ordered then all outputs are written in the order of the job that produced them.
+type emitter struct {
+	stream   Stream
+	cancel   <-chan struct{}
+	ordered  bool
+	next     uint64
+	pending  map[uint64]*pending
+	trailing []*output
+}
+
+func (e *emitter) write(o *output) error {
+	return e.stream.With(o.frame).Write(e.cancel, o.obj)
+}
+
+// advance writes the pending outputs of all completed jobs following the current job
+func (e *emitter) advance() error {
+	for {
+		e.next++
+		p, ok := e.pending[e.next]
+		if !ok {
+			return nil
+		}
+		for _, o := range p.outputs {
+			err := e.write(o)
+			if err != nil {
+				return err
+			}
+		}
+		p.outputs = nil
+		if !p.done {
+			return nil
+		}
+		delete(e.pending, e.next)
+	}
+}
+
+func (e *emitter) emit(o *output) error {
+	switch {
+	case o.trailing && e.ordered:
+		e.trailing = append(e.trailing, o)
+		return nil
+	case o.done && e.ordered:
+		if o.seq == e.next {
+			delete(e.pending, o.seq)
+			return e.advance()
+		}
+		e.get(o.seq).done = true
+		return nil
+	case o.done:
+		return nil
+	case !e.ordered || o.seq == e.next:
+		return e.write(o)
+	default:
+		p := e.get(o.seq)
+		p.outputs = append(p.outputs, o)
+		return nil
+	}
+}
+
+func (e *emitter) get(seq uint64) *pending {
+	p, ok := e.pending[seq]
+	if !ok {
+		p = new(pending)
+		e.pending[seq] = p
+	}
+	return p
+}
+
+// flush writes all outputs written after the workers read their last job
+func (e *emitter) flush() error {
+	for _, o := range e.trailing {
+		err := e.write(o)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParallelPipe runs many copies of a pipe concurrently, each reading from the same input stream.
+// If Ordered then all objects are written in the same order as the input frames that produced them,
+// buffering the output of any frame completed ahead of an earlier one.
+type ParallelPipe struct {
+	Pipes   []Pipe
+	Ordered bool
+}
+
+func (p *ParallelPipe) dispatch(ctx context.Context, stream Stream, jobs chan<- *job) error {
+	defer close(jobs)
+	for seq := uint64(0); ; seq++ {
+		f, err := stream.Read(ctx.Done())
+		if err != nil {
+			return err
+		}
+
+		select {
+		case jobs <- &job{seq: seq, frame: f}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (p *ParallelPipe) Go(ctx context.Context, stream Stream) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		jobs = make(chan *job)
+		out  = make(chan *output)
+		errs = make(chan error, len(p.Pipes)+1)
+	)
+
+	go func() {
+		err := p.dispatch(ctx, stream, jobs)
+		if errors.Cause(err) != io.EOF {
+			errs <- err
+		}
+	}()
+
+	for i := range p.Pipes {
+		wg.Add(1)
+		go func(x Pipe) {
+			defer wg.Done()
+			w := &worker{
+				ctx:  ctx,
+				jobs: jobs,
+				out:  out,
+			}
+			err := x.Go(ctx, &workerStream{worker: w})
+			if err == nil || errors.Cause(err) == io.EOF {
+				err = w.finish(nil)
+			}
+			if err != nil && errors.Cause(err) != io.EOF {
+				errs <- errors.Wrapf(err, "%T", x)
+			}
+		}(p.Pipes[i])
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	e := &emitter{
+		stream:  stream,
+		cancel:  ctx.Done(),
+		ordered: p.Ordered,
+		pending: make(map[uint64]*pending),
+	}
+	for {
+		select {
+		case o, ok := <-out:
+			if !ok {
+				return e.flush()
+			}
+			err := e.emit(o)
+			if err != nil {
+				return err
+			}
+		case err := <-errs:
+			return err
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package pipe
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+// sliceSourcePipe writes each of its values
+type sliceSourcePipe []interface{}
+
+func (p sliceSourcePipe) Go(ctx context.Context, stream Stream) error {
+	for _, x := range p {
+		err := stream.Write(nil, x)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectPipe collects all frames it receives
+type collectPipe struct {
+	Frames []*DataFrame
+}
+
+func (p *collectPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+		p.Frames = append(p.Frames, f)
+	}
+}
+
+// slowPipe writes each input number twice, sleeping longer for smaller numbers
+type slowPipe struct{}
+
+func (slowPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+		n := f.Object.(int)
+		time.Sleep(time.Duration(10-n) * 5 * time.Millisecond)
+		for i := 0; i < 2; i++ {
+			err = stream.Write(nil, n)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func runParallelTest(t *testing.T, ordered bool) []*DataFrame {
+	var (
+		source = sliceSourcePipe{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+		result = new(collectPipe)
+	)
+	err := Run(context.Background(), []Runnable{
+		{Pipe: source, Tag: NewTag("in")},
+		{Pipe: &ParallelPipe{Pipes: []Pipe{slowPipe{}, slowPipe{}, slowPipe{}, slowPipe{}}, Ordered: ordered}, Tag: NewTag("out")},
+		{Pipe: result},
+	}).ErrorOrNil()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Frames) != 2*len(source) {
+		t.Fatalf("expected %d results but got %d", 2*len(source), len(result.Frames))
+	}
+	for i, f := range result.Frames {
+		if f.Index != uint64(i) {
+			t.Fatalf("expected frame %d to have index %d but got %d", i, i, f.Index)
+		}
+		if f.Stack["in"] != f.Object {
+			t.Fatalf("expected frame %d to have the input %v in the stack but got %v", i, f.Object, f.Stack["in"])
+		}
+		if f.Tag.String() != "out" {
+			t.Fatalf("expected frame %d to be tagged %q but got %q", i, "out", f.Tag)
+		}
+	}
+	return result.Frames
+}
+
+func TestParallelPipe(t *testing.T) {
+	t.Run("ordered", func(t *testing.T) {
+		frames := runParallelTest(t, true)
+		for i, f := range frames {
+			if f.Object != i/2 {
+				t.Fatalf("expected frame %d to be %d but got %v", i, i/2, f.Object)
+			}
+		}
+	})
+	t.Run("unordered", func(t *testing.T) {
+		frames := runParallelTest(t, false)
+		var values = make([]int, len(frames))
+		for i, f := range frames {
+			values[i] = f.Object.(int)
+		}
+		if sort.IntsAreSorted(values) {
+			t.Fatalf("expected unordered results but got %v", values)
+		}
+	})
+}
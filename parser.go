@@ -1,24 +1,26 @@
 package pipe
 
 import (
-	"fmt"
 	"github.com/pkg/errors"
 	"github.com/relvacode/pipe/console"
 	"github.com/relvacode/pipe/dsl"
 	"github.com/sirupsen/logrus"
 	"io"
+	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // ScriptReaderOf obtains the script reader for the given args string.
 // This string can either be a file name or a pipe script directly.
-func ScriptReaderOf(command string) (io.Reader, error) {
+// In a script file $1, $2... outside of quotes and templates are replaced by the script arguments and $$ by $.
+func ScriptReaderOf(command string, args ...string) (io.Reader, error) {
 	i, err := os.Stat(command)
 	if os.IsNotExist(err) {
 		return strings.NewReader(command), nil
 	}
-	fmt.Println(err)
 	if err != nil {
 		return nil, err
 	}
@@ -26,16 +28,98 @@ func ScriptReaderOf(command string) (io.Reader, error) {
 		return nil, errors.Errorf("%q is a directory not a script file", command)
 	}
 
-	return os.Open(command)
+	b, err := ioutil.ReadFile(command)
+	if err != nil {
+		return nil, err
+	}
+	script, err := Expand(string(b), args)
+	if err != nil {
+		return nil, errors.Wrap(err, command)
+	}
+	return strings.NewReader(script), nil
+}
+
+// Expand replaces $1, $2... in the script with the script arguments and $$ with $.
+// Nothing is replaced inside quotes, templates or comments, so that arguments such as awk '{print $1}' are left as they are.
+// Any other $ is left as it is.
+func Expand(script string, args []string) (string, error) {
+	var (
+		b        strings.Builder
+		runes    = []rune(script)
+		quote    rune // the quote the script is inside of, if any
+		template int  // how many templates the script is inside of
+		comment  bool // the script is inside a comment line
+		line     = true
+	)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		var next rune
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case r == '\n':
+			comment, line = false, true
+		case comment:
+		case line && r == '#':
+			comment = true
+		case quote != 0:
+			if r == '\\' && next != 0 {
+				b.WriteRune(r)
+				r = next
+				i++
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '{' && (next == '{' || next == '%'):
+			template++
+			b.WriteRune(r)
+			r = next
+			i++
+		case template > 0:
+			if (r == '}' || r == '%') && next == '}' {
+				template--
+				b.WriteRune(r)
+				r = next
+				i++
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '$' && next == '$':
+			i++
+		case r == '$' && unicode.IsDigit(next):
+			j := i + 1
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			n, err := strconv.Atoi(string(runes[i+1 : j]))
+			if err != nil {
+				return "", err
+			}
+			if n < 1 || n > len(args) {
+				return "", errors.Errorf("script argument $%d not given", n)
+			}
+			b.WriteString(args[n-1])
+			i = j - 1
+			line = false
+			continue
+		}
+		if r != '\n' && !unicode.IsSpace(r) {
+			line = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), nil
 }
 
-func Make(name string, cmd string, from registry) (Pipe, error) {
+func Make(name string, cmd string, from *Registry) (Pipe, error) {
 	logrus.Debugf("creating pipe %q using %q", name, cmd)
 	if name == "" {
 		return nil, errors.New("missing pipe name")
 	}
 	// Get the module from the Pipes
-	pkg, ok := from[name]
+	pkg, ok := from.Lookup(name)
 	if !ok {
 		pkg = NewExecPkg(name)
 	}
@@ -47,35 +131,135 @@ func Make(name string, cmd string, from registry) (Pipe, error) {
 
 	err := c.Set(cmd)
 	if err != nil {
-		return nil, errors.Wrapf(err, "parse %q for %q", cmd, name)
+		return nil, errors.Wrap(err, name)
 	}
 	return p, nil
 }
 
-func Parse(r io.Reader, reg registry) ([]Runnable, error) {
+func Parse(r io.Reader, reg *Registry) ([]Runnable, error) {
 	pipes, err := dsl.Parse(r)
 	if err != nil {
 		return nil, err
 	}
 
+	return build(pipes, reg)
+}
+
+// build creates runnable pipes from parsed commands.
+// Bracketed groups are created as a ForkPipe of their sub-pipeline.
+func build(pipes []*dsl.Command, reg *Registry) ([]Runnable, error) {
 	var rn = make([]Runnable, len(pipes))
 	for i, c := range pipes {
-		p, err := Make(c.Name(), c.Args.String(), reg)
-		if err != nil {
-			return nil, errors.Wrapf(err, "create pipe %d", i)
+		if pkg, ok := reg.Lookup(c.Name()); ok && pkg.Stateful && c.Group() == nil && c.Branches() == nil && restarts(c.Modifiers()) {
+			return nil, c.Err(errors.Errorf("%s keeps state across inputs so it can't be used with errors, retries or deadletter", c.Name()))
 		}
-		rn[i] = Runnable{
-			Tag:  NewTag(c.Tag()),
-			Pipe: p,
+		r, err := Modify(constructor(c, reg), c.Modifiers(), reg)
+		if err != nil {
+			if oe, ok := errors.Cause(err).(*console.OptionError); ok {
+				return nil, c.ArgErr(oe.Input, err)
+			}
+			return nil, c.Err(err)
 		}
+		r.Tag = NewTag(c.Tag())
+		r.Input, r.Output = kindsOf(c, reg)
+		r.source = c
+		rn[i] = r
 	}
 
 	return rn, nil
 }
 
-// Returns the help text for a pipe builder
-func Help(pipe Pkg) string {
-	cmd := console.NewCommand()
-	pipe.Constructor(cmd)
-	return fmt.Sprintf("%s\t%s", pipe.Name, cmd.Usage())
+// kindsOf returns the kinds of object read and written by the pipe described by c.
+// A group writes a list for each input, and the first pipe of the group is checked against its input instead.
+func kindsOf(c *dsl.Command, reg *Registry) (input, output []Kind) {
+	switch {
+	case c.Branches() != nil:
+		return nil, nil
+	case c.Group() != nil:
+		return nil, []Kind{List}
+	}
+	pkg, ok := reg.Lookup(c.Name())
+	if !ok {
+		return nil, nil
+	}
+	return pkg.Input, pkg.Output
+}
+
+// A BranchFn creates a branching pipe from its arguments and branches
+type BranchFn func(args string, branches []Branch) (Pipe, error)
+
+// branching are the pipes that can be created from a list of bracketed branches.
+var branching = map[string]BranchFn{
+	"tee":   NewTeePipe,
+	"merge": NewMergePipe,
+}
+
+// NewTeePipe creates a TeePipe from its arguments
+func NewTeePipe(args string, branches []Branch) (Pipe, error) {
+	var (
+		cmd    = console.NewCommand()
+		buffer = cmd.Option("buffer").Default(64).Int()
+		drop   = cmd.Option("drop").Default(false).Bool()
+	)
+	err := cmd.Set(args)
+	if err != nil {
+		return nil, errors.Wrap(err, "tee")
+	}
+	return &TeePipe{
+		Branches: branches,
+		Buffer:   int(*buffer),
+		Drop:     *drop,
+	}, nil
+}
+
+// NewMergePipe creates a MergePipe from its arguments
+func NewMergePipe(args string, branches []Branch) (Pipe, error) {
+	err := console.NewCommand().Set(args)
+	if err != nil {
+		return nil, errors.Wrap(err, "merge")
+	}
+	return &MergePipe{
+		Branches: branches,
+	}, nil
+}
+
+// makeBranching creates a branching pipe and all of its branches
+func makeBranching(c *dsl.Command, reg *Registry) (Pipe, error) {
+	fn, ok := branching[c.Name()]
+	if !ok {
+		return nil, errors.Errorf("%q does not accept branches", c.Name())
+	}
+
+	var branches = make([]Branch, len(c.Branches()))
+	for i, b := range c.Branches() {
+		modules, err := build(b.Group(), reg)
+		if err != nil {
+			return nil, err
+		}
+		branches[i] = Branch{
+			Tag:   NewTag(b.Tag()),
+			Pipes: modules,
+		}
+	}
+	return fn(c.Args.String(), branches)
+}
+
+// constructor returns a function that creates a new instance of the pipe described by c
+func constructor(c *dsl.Command, reg *Registry) Constructor {
+	return func() (Pipe, error) {
+		if c.Branches() != nil {
+			return makeBranching(c, reg)
+		}
+
+		group := c.Group()
+		if group == nil {
+			return Make(c.Name(), c.Args.String(), reg)
+		}
+
+		modules, err := build(group, reg)
+		if err != nil {
+			return nil, err
+		}
+		return ForkPipe(modules), nil
+	}
 }
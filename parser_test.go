@@ -3,6 +3,7 @@ package pipe
 import (
 	"context"
 	"github.com/relvacode/pipe/console"
+	"github.com/relvacode/pipe/dsl"
 	"strings"
 	"testing"
 )
@@ -15,13 +16,14 @@ func (TestPipe) Go(context.Context, Stream) error {
 
 func TestParse(t *testing.T) {
 	t.Run("pipeline 1", func(t *testing.T) {
-		i, err := Parse(strings.NewReader("test"), registry{
-			"test": Pkg{
+		i, err := Parse(strings.NewReader("test"), NewRegistry(
+			Pkg{
+				Name: "test",
 				Constructor: func(*console.Command) Pipe {
 					return TestPipe{}
 				},
 			},
-		})
+		))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -35,7 +37,7 @@ func TestParse(t *testing.T) {
 	})
 
 	t.Run("exec fallback", func(t *testing.T) {
-		i, err := Parse(strings.NewReader("exec a b c"), registry{})
+		i, err := Parse(strings.NewReader("exec a b c"), NewRegistry())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -51,4 +53,109 @@ func TestParse(t *testing.T) {
 			t.Fatalf("expected args %s but got %s", want, *e.args)
 		}
 	})
+
+	t.Run("group", func(t *testing.T) {
+		i, err := Parse(strings.NewReader("test :: ( test :: test ) as g :: test"), NewRegistry(
+			Pkg{
+				Name: "test",
+				Constructor: func(*console.Command) Pipe {
+					return TestPipe{}
+				},
+			},
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(i) != 3 {
+			t.Fatalf("Expected 3 parsed modules, got %d", len(i))
+		}
+		f, ok := i[1].Pipe.(ForkPipe)
+		if !ok {
+			t.Fatalf("expected pipe instance to be %T not %T", ForkPipe{}, i[1].Pipe)
+		}
+		if len(f) != 2 {
+			t.Fatalf("Expected 2 modules in group, got %d", len(f))
+		}
+		if i[1].Tag.String() != "g" {
+			t.Fatalf("expected tag %q but got %q", "g", i[1].Tag)
+		}
+	})
+
+	t.Run("modifiers", func(t *testing.T) {
+		i, err := Parse(strings.NewReader("test with jobs=4 unordered"), NewRegistry(
+			Pkg{
+				Name: "test",
+				Constructor: func(*console.Command) Pipe {
+					return TestPipe{}
+				},
+			},
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, ok := i[0].Pipe.(*ParallelPipe)
+		if !ok {
+			t.Fatalf("expected pipe instance to be %T not %T", new(ParallelPipe), i[0].Pipe)
+		}
+		if len(p.Pipes) != 4 || p.Ordered {
+			t.Fatalf("expected 4 unordered pipes but got %d (ordered: %t)", len(p.Pipes), p.Ordered)
+		}
+
+		_, err = Parse(strings.NewReader("test with jobs=2 unknown"), NewRegistry())
+		if err == nil || !strings.Contains(err.Error(), "unknown") {
+			t.Fatalf("expected an error for an unknown modifier but got %v", err)
+		}
+	})
+
+	t.Run("option error", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("test :: test -n x"), NewRegistry(
+			Pkg{
+				Name: "test",
+				Constructor: func(c *console.Command) Pipe {
+					c.Option("n").Default(0).Int()
+					return TestPipe{}
+				},
+			},
+		))
+		e, ok := dsl.AsError(err)
+		if !ok {
+			t.Fatalf("expected %T but got %T (%v)", e, err, err)
+		}
+		if e.Column != 17 || e.Length != 1 || e.Stage != "test -n x" {
+			t.Fatalf("expected error for x at column 17 of %q but got %s of %q", "test -n x", e.Position, e.Stage)
+		}
+		if !strings.HasPrefix(e.Err.Error(), "test: option -n:") {
+			t.Fatalf("expected error to name the pipe and option but got %q", e.Err)
+		}
+	})
+}
+
+func TestExpand(t *testing.T) {
+	cases := []struct {
+		Script string
+		Expect string
+	}{
+		{"print $1 $2", "print a b"},
+		{"print $$1 $", "print $1 $"},
+		{"print $HOME", "print $HOME"},
+		{"exec awk {print $$2}", "exec awk {print $2}"},
+		{"exec awk '{print $1}' $1", "exec awk '{print $1}' a"},
+		{`exec sh -c "echo $1 \" $$" $2`, `exec sh -c "echo $1 \" $$" b`},
+		{"print {{ args.0 | default:'$1' }} $1", "print {{ args.0 | default:'$1' }} a"},
+		{"# it's $1\nprint $1", "# it's $1\nprint a"},
+	}
+	for _, tc := range cases {
+		got, err := Expand(tc.Script, []string{"a", "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.Expect {
+			t.Fatalf("expected %q but got %q", tc.Expect, got)
+		}
+	}
+
+	_, err := Expand("print $3", []string{"a", "b"})
+	if err == nil {
+		t.Fatal("expected an error for a missing argument")
+	}
 }
@@ -0,0 +1,261 @@
+package pipe
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/dsl"
+	"github.com/relvacode/pipe/tap"
+	"io"
+	"strings"
+)
+
+// An Option configures a Pipeline
+type Option func(*Pipeline)
+
+// WithRegistry looks up the pipes of the script in reg instead of Lib
+func WithRegistry(reg *Registry) Option {
+	return func(p *Pipeline) {
+		p.registry = reg
+	}
+}
+
+// WithPkg defines pkgs for this pipeline only, hiding any pipe of the same name in its registry
+func WithPkg(pkgs ...Pkg) Option {
+	return func(p *Pipeline) {
+		p.pkgs = append(p.pkgs, pkgs...)
+	}
+}
+
+// WithAlias defines an alias for this pipeline only that runs script in its place
+func WithAlias(name, script string) Option {
+	return func(p *Pipeline) {
+		p.aliases = append(p.aliases, [2]string{name, script})
+	}
+}
+
+// WithBuffer sets the number of frames buffered between each pipe instead of using DefaultBuffer
+func WithBuffer(n int) Option {
+	return func(p *Pipeline) {
+		p.buffer = n
+	}
+}
+
+// WithInput starts the pipeline with the frames written by source.
+// The default is a single empty frame.
+func WithInput(source Pipe) Option {
+	return func(p *Pipeline) {
+		p.input = source
+	}
+}
+
+// WithObjects starts the pipeline with a frame for each object.
+// The same objects are written by every run, so readers can only be read by the first.
+func WithObjects(objects ...interface{}) Option {
+	return WithInput(objectsPipe(objects))
+}
+
+// WithInputChan starts the pipeline with a frame for each object received from c until it is closed
+func WithInputChan(c <-chan interface{}) Option {
+	return WithInput(chanPipe(c))
+}
+
+// WithInputFunc starts the pipeline with a frame for each object returned by next until it returns io.EOF
+func WithInputFunc(next func(ctx context.Context) (interface{}, error)) Option {
+	return WithInput(funcPipe(next))
+}
+
+// WithOutput reads the frames written by the last pipe of the script with sink.
+// The default discards every frame.
+func WithOutput(sink Pipe) Option {
+	return func(p *Pipeline) {
+		p.output = sink
+	}
+}
+
+// WithOutputChan sends each frame written by the last pipe of the script to c.
+// The channel isn't closed, once Run returns nothing else is sent.
+func WithOutputChan(c chan<- *DataFrame) Option {
+	return WithOutputFunc(func(ctx context.Context, f *DataFrame) error {
+		select {
+		case c <- f:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// WithOutputFunc calls fn with each frame written by the last pipe of the script.
+// If fn returns an error then the pipeline is stopped.
+func WithOutputFunc(fn func(ctx context.Context, f *DataFrame) error) Option {
+	return WithOutput(sinkPipe(fn))
+}
+
+// WithWriter writes each frame written by the last pipe of the script to w
+func WithWriter(w io.Writer, output Output) Option {
+	return WithOutput(&EchoPipe{Writer: w, Output: output})
+}
+
+// Pipeline is a parsed pipe script along with its input and output, which can be run any number of times.
+type Pipeline struct {
+	commands []*dsl.Command
+	registry *Registry
+	pkgs     []Pkg
+	aliases  [][2]string
+	buffer   int
+	input    Pipe
+	output   Pipe
+	warnings []error
+}
+
+// New parses script into a pipeline configured by opts, using the pipes of Lib unless WithRegistry is given.
+// Pipes and aliases defined with WithPkg and WithAlias are layered on top of the registry.
+// Any problem found checking the pipeline is available from Warnings.
+func New(script string, opts ...Option) (*Pipeline, error) {
+	var p = &Pipeline{
+		registry: Lib,
+		input:    EmptyPipe{},
+		output:   discardPipe{},
+	}
+	for _, o := range opts {
+		o(p)
+	}
+
+	if len(p.pkgs) > 0 || len(p.aliases) > 0 {
+		p.registry = p.registry.Layer(p.pkgs...)
+	}
+	for _, a := range p.aliases {
+		pkg, err := NewAliasPkg(a[0], a[1], p.registry)
+		if err != nil {
+			return nil, errors.Wrapf(err, "alias %q", a[0])
+		}
+		p.registry.Define(pkg)
+	}
+
+	var err error
+	p.commands, err = dsl.Parse(strings.NewReader(script))
+	if err != nil {
+		return nil, err
+	}
+	modules, err := p.build()
+	if err != nil {
+		return nil, err
+	}
+	p.warnings = Check(modules)
+	return p, nil
+}
+
+// build creates a new instance of each pipe in the script
+func (p *Pipeline) build() ([]Runnable, error) {
+	modules, err := build(p.commands, p.registry)
+	if err != nil {
+		return nil, err
+	}
+	if p.buffer != 0 {
+		for i := range modules {
+			modules[i].Buffer = p.buffer
+		}
+	}
+	return modules, nil
+}
+
+// Warnings returns the problems found checking each pipe can read what the pipe before it writes
+func (p *Pipeline) Warnings() []error {
+	return p.warnings
+}
+
+// Run runs the pipeline until all input has been processed or ctx is cancelled.
+// Each run creates new instances of its pipes.
+// Anything deferred by pipes during the run, such as removing temporary files, is done before Run returns.
+func (p *Pipeline) Run(ctx context.Context) error {
+	modules, err := p.build()
+	if err != nil {
+		return err
+	}
+
+	var scope = tap.NewScope()
+	errs := RunIO(tap.WithScope(ctx, scope), p.input, modules, p.output)
+	errs = append(errs, scope.Exit().WrappedErrors()...)
+	return errs.ErrorOrNil()
+}
+
+// objectsPipe writes each of its objects
+type objectsPipe []interface{}
+
+func (p objectsPipe) Go(ctx context.Context, stream Stream) error {
+	for _, x := range p {
+		err := stream.Write(nil, x)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chanPipe writes each object received from the channel until it is closed
+type chanPipe <-chan interface{}
+
+func (p chanPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		select {
+		case x, ok := <-p:
+			if !ok {
+				return nil
+			}
+			err := stream.Write(nil, x)
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// funcPipe writes each object returned by the function until it returns io.EOF
+type funcPipe func(ctx context.Context) (interface{}, error)
+
+func (p funcPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		x, err := p(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = stream.Write(nil, x)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sinkPipe calls the function with each frame it reads
+type sinkPipe func(ctx context.Context, f *DataFrame) error
+
+func (p sinkPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+		err = p(ctx, f)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// discardPipe reads and closes every frame
+type discardPipe struct{}
+
+func (discardPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+		_ = tap.Close(f.Object)
+	}
+}
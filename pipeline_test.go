@@ -0,0 +1,184 @@
+package pipe
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/console"
+	"github.com/relvacode/pipe/dsl"
+	"github.com/relvacode/pipe/tap"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// renderPipe writes its template rendered for each frame
+type renderPipe struct {
+	template *tap.Template
+}
+
+func (p renderPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+		s, err := p.template.Render(f.Context())
+		if err != nil {
+			return err
+		}
+		err = stream.Write(nil, s)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+var renderRegistry = NewRegistry(
+	Pkg{
+		Name: "render",
+		Constructor: func(console *console.Command) Pipe {
+			return renderPipe{template: console.Arg(0).Template()}
+		},
+	},
+)
+
+func TestPipeline(t *testing.T) {
+	var c = make(chan *DataFrame, 10)
+	p, err := New("render {{this}}!", WithRegistry(renderRegistry), WithObjects(1, 2), WithOutputChan(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 0; run < 2; run++ {
+		err = p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []interface{}
+		for len(c) > 0 {
+			got = append(got, (<-c).Object)
+		}
+		if expect := []interface{}{"1!", "2!"}; !reflect.DeepEqual(got, expect) {
+			t.Fatalf("run %d: expected %v but got %v", run, expect, got)
+		}
+	}
+}
+
+func TestPipeline_Func(t *testing.T) {
+	var (
+		n        int
+		stopped  = errors.New("stopped")
+		received []interface{}
+	)
+	p, err := New("render {{this}}",
+		WithRegistry(renderRegistry),
+		WithInputFunc(func(context.Context) (interface{}, error) {
+			n++
+			if n > 5 {
+				return nil, io.EOF
+			}
+			return n, nil
+		}),
+		WithOutputFunc(func(_ context.Context, f *DataFrame) error {
+			received = append(received, f.Object)
+			if len(received) == 3 {
+				return stopped
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.Run(context.Background())
+	if e, ok := err.(RuntimeError); !ok || len(e) != 1 || errors.Cause(e[0]) != stopped {
+		t.Fatalf("expected the output to stop the pipeline but got %v", err)
+	}
+	if expect := []interface{}{"1", "2", "3"}; !reflect.DeepEqual(received, expect) {
+		t.Fatalf("expected %v but got %v", expect, received)
+	}
+}
+
+func TestPipeline_Scope(t *testing.T) {
+	var paths []string
+	p, err := New("render {{this|mktemp}}",
+		WithRegistry(renderRegistry),
+		WithObjects("a", "b"),
+		WithOutputFunc(func(_ context.Context, f *DataFrame) error {
+			path := f.Object.(string)
+			if _, err := os.Stat(path); err != nil {
+				return err
+			}
+			paths = append(paths, path)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 temporary files but got %d", len(paths))
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed once the run finished but got %v", path, err)
+		}
+	}
+
+	// The file is removed even if the rendered template doesn't contain its whole path
+	var (
+		root = os.TempDir()[:1]
+		path string
+	)
+	p, err = New(`render '{{this|mktemp|slice:"1:"}}'`,
+		WithRegistry(renderRegistry),
+		WithObjects("a"),
+		WithOutputFunc(func(_ context.Context, f *DataFrame) error {
+			path = root + f.Object.(string)
+			_, err := os.Stat(path)
+			return err
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed once the run finished but got %v", path, err)
+	}
+}
+
+func TestPipeline_Alias(t *testing.T) {
+	var c = make(chan *DataFrame, 10)
+	p, err := New("shout", WithRegistry(renderRegistry), WithAlias("shout", "render {{this}}!"), WithObjects("a"), WithOutputChan(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := <-c; f.Object != "a!" {
+		t.Fatalf("expected the alias to be run but got %v", f.Object)
+	}
+	if _, ok := renderRegistry.Lookup("shout"); ok {
+		t.Fatal("expected the alias to only be defined for the pipeline")
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New("render x ::", WithRegistry(renderRegistry))
+	if _, ok := dsl.AsError(err); !ok {
+		t.Fatalf("expected a script error but got %v", err)
+	}
+}
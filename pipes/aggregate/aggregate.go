@@ -18,7 +18,7 @@ type Aggregation interface {
 
 func NewAggregator(command *console.Command, f func() Aggregation) *Pipe {
 	return &Pipe{
-		Of:   command.Any().Expression(),
+		Of:   command.Any().Describe("An expression evaluated for each input").Expression(),
 		Init: f,
 	}
 }
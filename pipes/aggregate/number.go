@@ -10,7 +10,16 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "sum",
+		Name:        "sum",
+		Description: "Add up the result of an expression for every input and write the total once the input has ended",
+		Output:      []pipe.Kind{pipe.Number},
+		Stateful:    true,
+		Examples: []pipe.Example{
+			{
+				Description: "Total the size of all JSON files",
+				Script:      "open *.json :: sum this.Size",
+			},
+		},
 		Constructor: func(command *console.Command) pipe.Pipe {
 			return NewAggregator(command, func() Aggregation {
 				return NewNumber(func(values []float64) (s float64) {
@@ -16,12 +16,20 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "browser",
+		Name:        "browser",
+		Description: "Stream every input to a web page",
+		Output:      []pipe.Kind{pipe.Nothing},
+		Examples: []pipe.Example{
+			{
+				Description: "Follow a log file at http://127.0.0.1:3003",
+				Script:      "tail -f app.log :: split :: browser",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return &BrowserPipe{
-				addr: console.Arg(0).Default("127.0.0.1:3003").String(),
-				fg:   console.Option("fg").Default("#f9f9f9").String(),
-				bg:   console.Option("bg").Default("#1b1b1b").String(),
+				addr: console.Arg(0).Default("127.0.0.1:3003").Describe("The address to serve the page on").String(),
+				fg:   console.Option("fg").Default("#f9f9f9").Describe("The text colour of the page").String(),
+				bg:   console.Option("bg").Default("#1b1b1b").Describe("The background colour of the page").String(),
 				data: make(chan *BufSend),
 			}
 		},
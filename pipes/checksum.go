@@ -25,7 +25,16 @@ func init() {
 	for k := range allHashGenerators {
 		g := allHashGenerators[k]
 		pipe.Define(pipe.Pkg{
-			Name: pipe.Family("checksum", k),
+			Name:        pipe.Family("checksum", k),
+			Description: fmt.Sprintf("Write the hex encoded %s checksum of every input", k),
+			Input:       []pipe.Kind{pipe.Reader, pipe.String},
+			Output:      []pipe.Kind{pipe.String},
+			Examples: []pipe.Example{
+				{
+					Description: fmt.Sprintf("Get the %s checksum of each file", k),
+					Script:      fmt.Sprintf("open * :: checksum.%s as sum :: print {{sum}}", k),
+				},
+			},
 			Constructor: func(command *console.Command) pipe.Pipe {
 				return &ChecksumPipe{g: g}
 			},
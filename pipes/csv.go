@@ -1,44 +1,240 @@
 package pipes
 
 import (
+	"bytes"
 	"context"
 	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
 	"github.com/relvacode/pipe"
 	"github.com/relvacode/pipe/console"
 	"github.com/relvacode/pipe/tap"
 	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "csv",
-		Constructor: func(console *console.Command) pipe.Pipe {
-			return CSVPipe{}
+		Name:        "csv",
+		Description: "Decode CSV from every reader, or every string as a line with -lines, writing each row as a map of column to value, or encode any other input as CSV rows",
+		Input:       []pipe.Kind{pipe.Reader, pipe.String, pipe.Map, pipe.List},
+		Output:      []pipe.Kind{pipe.Map, pipe.Reader},
+		Examples: []pipe.Example{
+			{
+				Description: "Print the name column of a CSV file",
+				Script:      "open users.csv :: csv :: print {{this.name}}",
+			},
+			{
+				Description: "Sum a column of numbers from a CSV file without a header row",
+				Script:      "open sizes.csv :: csv -noheader=true -columns name,size -infer=true :: sum this.size",
+			},
+			{
+				Description: "Decode CSV that has already been split into lines",
+				Script:      "split :: csv -lines=true :: print {{this.name}}",
+			},
+			{
+				Description: "Encode the name and size of each file as CSV",
+				Script:      "open * :: select {name: this.Name, size: this.Size} :: csv",
+			},
 		},
+		Constructor: csvConstructor(","),
 	})
+	pipe.Define(pipe.Pkg{
+		Name:        "tsv",
+		Description: "Decode or encode tab separated values, the same as csv -delimiter '\\t'",
+		Input:       []pipe.Kind{pipe.Reader, pipe.String, pipe.Map, pipe.List},
+		Output:      []pipe.Kind{pipe.Map, pipe.Reader},
+		Examples: []pipe.Example{
+			{
+				Description: "Convert a TSV file to CSV",
+				Script:      "open users.tsv :: tsv :: csv",
+			},
+		},
+		Constructor: csvConstructor("\\t"),
+	})
+}
+
+func csvConstructor(delimiter string) func(*console.Command) pipe.Pipe {
+	return func(console *console.Command) pipe.Pipe {
+		return &CSVPipe{
+			Delimiter: console.Option("delimiter").Default(delimiter).Describe("The character that separates each field, escapes such as '\\t' are allowed").String(),
+			NoHeader:  console.Option("noheader").Default(false).Describe("Don't read or write a header row").Bool(),
+			Columns:   console.Option("columns").Default("").Describe("Comma separated names of each column, used instead of the header row").String(),
+			Infer:     console.Option("infer").Default(false).Describe("Decode numbers and booleans instead of writing every field as a string").Bool(),
+			Quote:     console.Option("quote").Default(csvQuoteMinimal).Describe("Which encoded fields to quote, one of minimal, all or none").String(),
+			Lenient:   console.Option("lenient").Default(false).Describe("Allow rows with too few or too many fields and quotes in unquoted fields").Bool(),
+			Lines:     console.Option("lines").Default(false).Describe("Decode each string as the next line of one document, such as the lines written by split or pipe -lines").Bool(),
+		}
+	}
 }
 
+const (
+	// csvQuoteMinimal quotes fields that contain the delimiter, a quote or a new line
+	csvQuoteMinimal = "minimal"
+	// csvQuoteAll quotes every field
+	csvQuoteAll = "all"
+	// csvQuoteNone never quotes fields, even if that makes the row ambiguous
+	csvQuoteNone = "none"
+)
+
+// CSVPipe decodes readers as CSV, writing each row as a map of column to value.
+// Each reader is a whole document with its own header.
+// Strings are only decoded with Lines, where each string is the next line of a single document whose header is the first string.
+// Any other object is encoded as one or more rows of CSV:
+// maps and structs are rows of named fields, lists of values are rows of positional fields,
+// and lists of maps, structs or lists are encoded as a row for each item.
+//
+// Without a header the columns are named column1, column2 and so on.
+// When encoding, the columns are those of the first row with named fields.
 type CSVPipe struct {
+	Delimiter *string
+	NoHeader  *bool
+	Columns   *string
+	Infer     *bool
+	Quote     *string
+	Lenient   *bool
+	Lines     *bool
 }
 
-func (CSVPipe) readStream(r io.Reader, stream pipe.Stream) error {
-	var c = csv.NewReader(r)
-	var headers []string
+// comma returns the delimiter as a single character
+func (p *CSVPipe) comma() (rune, error) {
+	s, err := strconv.Unquote(`"` + *p.Delimiter + `"`)
+	if err != nil {
+		s = *p.Delimiter
+	}
+	r := []rune(s)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' || r[0] == utf8.RuneError {
+		return 0, errors.Errorf("invalid delimiter %q, expected a single character other than a quote or new line", *p.Delimiter)
+	}
+	return r[0], nil
+}
 
-	for i := 0; ; i++ {
+// columns returns the columns given to the pipe, if any
+func (p *CSVPipe) columns() []string {
+	if *p.Columns == "" {
+		return nil
+	}
+	columns := strings.Split(*p.Columns, ",")
+	for i, c := range columns {
+		columns[i] = strings.TrimSpace(c)
+	}
+	return columns
+}
+
+// csvColumn is the name of the column at i when there is no header
+func csvColumn(i int) string {
+	return "column" + strconv.Itoa(i+1)
+}
+
+// isNumber returns true if s only contains the characters of a decimal number
+// and has no leading zeros, which usually means that it's an identifier.
+func isNumber(s string) bool {
+	digits := strings.TrimLeft(s, "+-")
+	if digits == "" || (len(digits) > 1 && digits[0] == '0' && digits[1] != '.') {
+		return false
+	}
+	for _, c := range digits {
+		if (c < '0' || c > '9') && c != '.' && c != 'e' && c != 'E' && c != '+' && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// infer converts s into an integer, float or boolean if it looks like one
+func infer(s string) interface{} {
+	switch strings.ToLower(s) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if !isNumber(s) {
+		return s
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// csvDecoder decodes records as rows of a single document
+type csvDecoder struct {
+	p       *CSVPipe
+	comma   rune
+	columns []string
+	header  bool // the next record is the header
+	n       int  // the number of records decoded
+}
+
+func (p *CSVPipe) decoder(comma rune) *csvDecoder {
+	return &csvDecoder{
+		p:       p,
+		comma:   comma,
+		columns: p.columns(),
+		header:  !*p.NoHeader,
+	}
+}
+
+// decode writes each record of r as a row
+func (d *csvDecoder) decode(r io.Reader, stream pipe.Stream) error {
+	var (
+		p = d.p
+		c = csv.NewReader(r)
+	)
+	c.Comma = d.comma
+	c.FieldsPerRecord = -1
+	c.LazyQuotes = *p.Lenient
+
+	for {
 		record, err := c.Read()
+		if err == io.EOF {
+			return nil
+		}
+		d.n++
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "record %d", d.n)
 		}
 
-		if i == 0 {
-			headers = record
+		if d.header {
+			d.header = false
+			if d.columns == nil {
+				d.columns = record
+			}
 			continue
 		}
+		columns := d.columns
+		if columns == nil {
+			columns = make([]string, len(record))
+			for i := range columns {
+				columns[i] = csvColumn(i)
+			}
+			d.columns = columns
+		}
+		if len(record) != len(columns) && !*p.Lenient {
+			return errors.Errorf("record %d: expected %d fields but got %d", d.n, len(columns), len(record))
+		}
 
-		row := make(map[string]string, len(headers))
-		for i, k := range headers {
-			row[k] = record[i]
+		row := make(map[string]interface{}, len(record))
+		for i, v := range record {
+			k := csvColumn(i)
+			if i < len(columns) {
+				k = columns[i]
+			}
+			if *p.Infer {
+				row[k] = infer(v)
+			} else {
+				row[k] = v
+			}
 		}
 		err = stream.Write(nil, row)
 		if err != nil {
@@ -47,20 +243,234 @@ func (CSVPipe) readStream(r io.Reader, stream pipe.Stream) error {
 	}
 }
 
-func (p CSVPipe) Go(ctx context.Context, stream pipe.Stream) error {
-	for {
-		f, err := stream.Read(nil)
+// csvEncoder encodes objects as rows, writing the header before the first row
+type csvEncoder struct {
+	p       *CSVPipe
+	comma   rune
+	columns []string
+	header  bool // the header has been written
+}
+
+// csvCell formats a value as a single field
+func csvCell(x interface{}) (string, error) {
+	switch v := x.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	switch reflect.Indirect(reflect.ValueOf(x)).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		b, err := json.Marshal(x)
+		return string(b), err
+	}
+	return fmt.Sprint(x), nil
+}
+
+// write writes fields as a row quoted according to the quote rule of the pipe
+func (e *csvEncoder) write(b *bytes.Buffer, fields []string) error {
+	switch *e.p.Quote {
+	case csvQuoteAll:
+		for i, f := range fields {
+			if i > 0 {
+				b.WriteRune(e.comma)
+			}
+			b.WriteString(`"` + strings.Replace(f, `"`, `""`, -1) + `"`)
+		}
+		b.WriteString("\n")
+	case csvQuoteNone:
+		b.WriteString(strings.Join(fields, string(e.comma)) + "\n")
+	default:
+		w := csv.NewWriter(b)
+		w.Comma = e.comma
+		_ = w.Write(fields)
+		w.Flush()
+		return w.Error()
+	}
+	return nil
+}
+
+// writeHeader writes the columns if a header should be written and hasn't been yet
+func (e *csvEncoder) writeHeader(b *bytes.Buffer) error {
+	if e.header || *e.p.NoHeader || e.columns == nil {
+		return nil
+	}
+	e.header = true
+	return e.write(b, e.columns)
+}
+
+// named writes a row of values for each column
+func (e *csvEncoder) named(b *bytes.Buffer, keys []string, values map[string]interface{}) error {
+	if e.columns == nil {
+		e.columns = keys
+	}
+	err := e.writeHeader(b)
+	if err != nil {
+		return err
+	}
+
+	if !*e.p.Lenient {
+		var known = make(map[string]bool, len(e.columns))
+		for _, c := range e.columns {
+			known[c] = true
+		}
+		for _, k := range keys {
+			if !known[k] {
+				return errors.Errorf("%s is not a column of %s", k, strings.Join(e.columns, ","))
+			}
+		}
+	}
+
+	var fields = make([]string, len(e.columns))
+	for i, c := range e.columns {
+		fields[i], err = csvCell(values[c])
+		if err != nil {
+			return errors.Wrap(err, c)
+		}
+	}
+	return e.write(b, fields)
+}
+
+// positional writes a row of values in the order they are given
+func (e *csvEncoder) positional(b *bytes.Buffer, values []interface{}) error {
+	err := e.writeHeader(b)
+	if err != nil {
+		return err
+	}
+	if e.columns != nil && len(values) != len(e.columns) && !*e.p.Lenient {
+		return errors.Errorf("expected %d fields but got %d", len(e.columns), len(values))
+	}
+
+	var fields = make([]string, len(values))
+	for i, v := range values {
+		fields[i], err = csvCell(v)
 		if err != nil {
 			return err
 		}
+	}
+	return e.write(b, fields)
+}
+
+// isRow returns true if rv can be encoded as a row
+func isRow(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct:
+		return true
+	case reflect.Slice, reflect.Array:
+		return rv.Type().Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+// encode writes x as one or more rows
+func (e *csvEncoder) encode(b *bytes.Buffer, x interface{}) error {
+	var rv = reflect.Indirect(reflect.ValueOf(x))
+	if rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		var (
+			keys   = make([]string, 0, rv.Len())
+			values = make(map[string]interface{}, rv.Len())
+		)
+		for _, k := range rv.MapKeys() {
+			key := fmt.Sprint(k.Interface())
+			keys = append(keys, key)
+			values[key] = rv.MapIndex(k).Interface()
+		}
+		sort.Strings(keys)
+		return e.named(b, keys, values)
+
+	case reflect.Struct:
+		var (
+			keys   []string
+			values = make(map[string]interface{})
+		)
+		for i := 0; i < rv.NumField(); i++ {
+			if f := rv.Type().Field(i); f.PkgPath == "" {
+				keys = append(keys, f.Name)
+				values[f.Name] = rv.Field(i).Interface()
+			}
+		}
+		return e.named(b, keys, values)
+
+	case reflect.Slice, reflect.Array:
+		var (
+			values = make([]interface{}, rv.Len())
+			rows   = rv.Len() > 0
+		)
+		for i := range values {
+			values[i] = rv.Index(i).Interface()
+			if !isRow(reflect.Indirect(reflect.ValueOf(values[i]))) {
+				rows = false
+			}
+		}
+		if !rows {
+			return e.positional(b, values)
+		}
+		for i, v := range values {
+			err := e.encode(b, v)
+			if err != nil {
+				return errors.Wrapf(err, "item %d", i)
+			}
+		}
+		return nil
+	}
+	return errors.Errorf("cannot encode %T as CSV", x)
+}
+
+func (p *CSVPipe) Go(ctx context.Context, stream pipe.Stream) error {
+	comma, err := p.comma()
+	if err != nil {
+		return err
+	}
+	switch *p.Quote {
+	case csvQuoteMinimal, csvQuoteAll, csvQuoteNone:
+	default:
+		return errors.Errorf("unknown quote rule %q, expected one of %s, %s or %s", *p.Quote, csvQuoteMinimal, csvQuoteAll, csvQuoteNone)
+	}
 
-		r, err := tap.Reader(f.Object)
+	var (
+		e = &csvEncoder{
+			p:       p,
+			comma:   comma,
+			columns: p.columns(),
+		}
+		lines = p.decoder(comma)
+	)
+	for {
+		f, err := stream.Read(nil)
 		if err != nil {
 			return err
 		}
 
-		err = p.readStream(r, stream)
-		_ = tap.Close(r)
+		switch x := f.Object.(type) {
+		case io.Reader:
+			err = p.decoder(comma).decode(x, stream)
+			_ = tap.Close(x)
+
+		case string:
+			if !*p.Lines {
+				return errors.New("cannot decode a string as CSV, use -lines=true to decode each string as a line of one document")
+			}
+			err = lines.decode(strings.NewReader(x), stream)
+
+		default:
+			var b bytes.Buffer
+			err = e.encode(&b, x)
+			if err == nil {
+				err = stream.Write(nil, &b)
+			}
+		}
+
 		if err != nil {
 			return err
 		}
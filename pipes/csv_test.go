@@ -0,0 +1,152 @@
+package pipes
+
+import (
+	"context"
+	"github.com/relvacode/pipe"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runCSV runs script over objects, returning the objects it writes with readers read as strings
+func runCSV(t *testing.T, script string, objects ...interface{}) ([]interface{}, error) {
+	var results []interface{}
+	p, err := pipe.New(script, pipe.WithObjects(objects...), pipe.WithOutputFunc(func(_ context.Context, f *pipe.DataFrame) error {
+		if r, ok := f.Object.(io.Reader); ok {
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			results = append(results, string(b))
+			return nil
+		}
+		results = append(results, f.Object)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results, p.Run(context.Background())
+}
+
+func TestCSVPipe_Decode(t *testing.T) {
+	results, err := runCSV(t, "csv -infer=true", strings.NewReader("name,size,ok\na,1,true\nb,1.5,FALSE\n007,-2,x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []interface{}{
+		map[string]interface{}{"name": "a", "size": int64(1), "ok": true},
+		map[string]interface{}{"name": "b", "size": 1.5, "ok": false},
+		map[string]interface{}{"name": "007", "size": int64(-2), "ok": "x"},
+	}
+	if !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expected %v but got %v", expect, results)
+	}
+}
+
+func TestCSVPipe_Lines(t *testing.T) {
+	lines := []interface{}{"name,size", "a,1", "", "b,2"}
+	_, err := runCSV(t, "csv", lines...)
+	if err == nil || !strings.Contains(err.Error(), "-lines") {
+		t.Fatalf("expected an error decoding a string without -lines but got %v", err)
+	}
+
+	results, err := runCSV(t, "csv -lines=true", lines...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []interface{}{
+		map[string]interface{}{"name": "a", "size": "1"},
+		map[string]interface{}{"name": "b", "size": "2"},
+	}
+	if !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expected %v but got %v", expect, results)
+	}
+
+	_, err = runCSV(t, "csv -lines=true", "a,b", "1")
+	if err == nil || !strings.Contains(err.Error(), "record 2: expected 2 fields but got 1") {
+		t.Fatalf("expected an error decoding a short line but got %v", err)
+	}
+}
+
+func TestCSVPipe_Columns(t *testing.T) {
+	for script, expect := range map[string][]interface{}{
+		"tsv -noheader=true": {
+			map[string]interface{}{"column1": "a", "column2": "1"},
+			map[string]interface{}{"column1": "b", "column2": "2"},
+		},
+		"tsv -noheader=true -columns name,size": {
+			map[string]interface{}{"name": "a", "size": "1"},
+			map[string]interface{}{"name": "b", "size": "2"},
+		},
+		"tsv -columns name,size": {
+			map[string]interface{}{"name": "b", "size": "2"},
+		},
+	} {
+		results, err := runCSV(t, script, strings.NewReader("a\t1\nb\t2\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(results, expect) {
+			t.Fatalf("%s: expected %v but got %v", script, expect, results)
+		}
+	}
+}
+
+func TestCSVPipe_Lenient(t *testing.T) {
+	const input = "a,b\n1\n1,2,3\n"
+	_, err := runCSV(t, "csv", strings.NewReader(input))
+	if err == nil || !strings.Contains(err.Error(), "expected 2 fields but got 1") {
+		t.Fatalf("expected an error decoding a short row but got %v", err)
+	}
+
+	results, err := runCSV(t, "csv -lenient=true", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []interface{}{
+		map[string]interface{}{"a": "1"},
+		map[string]interface{}{"a": "1", "b": "2", "column3": "3"},
+	}
+	if !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expected %v but got %v", expect, results)
+	}
+}
+
+func TestCSVPipe_Encode(t *testing.T) {
+	type file struct {
+		Name string
+		Size int
+	}
+	results, err := runCSV(t, "csv",
+		map[string]interface{}{"b": 1, "a": "x,y"},
+		[]interface{}{map[string]interface{}{"a": nil, "b": true}, file{}},
+	)
+	if err == nil || !strings.Contains(err.Error(), "Name is not a column") {
+		t.Fatalf("expected an error encoding a struct with different columns but got %v", err)
+	}
+	if expect := []interface{}{"a,b\n\"x,y\",1\n"}; !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expected %q but got %q", expect, results)
+	}
+
+	results, err = runCSV(t, "csv -quote all -delimiter ; -columns Size",
+		[]file{{Name: "a", Size: 1}, {Name: `"b"`, Size: 2}},
+		[]interface{}{3},
+	)
+	if err == nil || !strings.Contains(err.Error(), "Name is not a column") {
+		t.Fatalf("expected an error encoding a field that isn't a column but got %v", err)
+	}
+
+	results, err = runCSV(t, "csv -quote all -delimiter ; -lenient=true -noheader=true",
+		[]file{{Name: "a", Size: 1}, {Name: `"b"`, Size: 2}},
+		[]interface{}{"c", 3},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []interface{}{"\"a\";\"1\"\n\"\"\"b\"\"\";\"2\"\n", "\"c\";\"3\"\n"}; !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expected %q but got %q", expect, results)
+	}
+}
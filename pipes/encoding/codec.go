@@ -0,0 +1,78 @@
+package encoding
+
+import (
+	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/console"
+	"github.com/ugorji/go/codec"
+	"io"
+	"reflect"
+)
+
+func init() {
+	var msgpack = &codec.MsgpackHandle{
+		RawToString: true,
+		WriteExt:    true,
+	}
+	decodeOptions(&msgpack.BasicHandle)
+	Define(`msgpack`, `MessagePack`, func(*console.Command) Protocol {
+		return CodecProtocol{Handle: msgpack}
+	})
+
+	var cbor = new(codec.CborHandle)
+	decodeOptions(&cbor.BasicHandle)
+	Define(`cbor`, `CBOR`, func(*console.Command) Protocol {
+		return CodecProtocol{Handle: cbor}
+	})
+}
+
+// decodeOptions decodes maps with string keys, the same as JSON, and integers as int64
+func decodeOptions(h *codec.BasicHandle) {
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.SignedInteger = true
+}
+
+// CodecProtocol encodes and decodes a binary format such as MessagePack or CBOR.
+// Each value in a reader is decoded as soon as all of it has been read,
+// so a stream of concatenated values is decoded one value at a time.
+type CodecProtocol struct {
+	Handle codec.Handle
+}
+
+func (p CodecProtocol) Encode(w io.Writer) Encoder {
+	return codec.NewEncoder(w, p.Handle).Encode
+}
+
+// countReader counts the bytes read from a reader
+type countReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.n += int64(n)
+	return n, err
+}
+
+// Decode decodes each value of r.
+// If r ends part way through a value then the error is io.ErrUnexpectedEOF.
+func (p CodecProtocol) Decode(r io.Reader) Decoder {
+	var (
+		cr = &countReader{Reader: r}
+		d  = codec.NewDecoder(cr, p.Handle)
+	)
+	return func() (interface{}, error) {
+		var (
+			x     interface{}
+			start = cr.n
+		)
+		err := d.Decode(&x)
+		switch {
+		case errors.Cause(err) != io.EOF:
+			return x, err
+		case cr.n > start:
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, io.EOF
+	}
+}
@@ -0,0 +1,86 @@
+package encoding
+
+import (
+	"context"
+	"github.com/relvacode/pipe"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodecProtocol(t *testing.T) {
+	objects := []interface{}{
+		map[string]interface{}{"id": 1, "name": "a"},
+		map[string]interface{}{"id": 2, "tags": []string{"x"}},
+	}
+	expect := []interface{}{
+		map[string]interface{}{"id": int64(1), "name": "a"},
+		map[string]interface{}{"id": int64(2), "tags": []interface{}{"x"}},
+	}
+
+	for _, name := range []string{"msgpack", "cbor"} {
+		encoded := encodeAll(t, name, objects...)
+		if len(encoded) != len(objects) {
+			t.Fatalf("%s: expected %d encoded values but got %d", name, len(objects), len(encoded))
+		}
+
+		var (
+			r, w   = io.Pipe()
+			frames = make(chan *pipe.DataFrame)
+		)
+		go func() {
+			for _, b := range encoded {
+				_, _ = io.WriteString(w, b)
+				// Wait until the value is decoded before writing the next
+				<-frames
+			}
+			_ = w.Close()
+		}()
+
+		var results []interface{}
+		p, err := pipe.New(name, pipe.WithObjects(r), pipe.WithOutputFunc(func(_ context.Context, f *pipe.DataFrame) error {
+			results = append(results, f.Object)
+			select {
+			case frames <- f:
+			case <-time.After(5 * time.Second):
+				t.Errorf("%s: expected each value to be decoded as soon as it is read", name)
+			}
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(results, expect) {
+			t.Fatalf("%s: expected %v but got %v", name, expect, results)
+		}
+
+		p, err = pipe.New(name, pipe.WithObjects(strings.NewReader(encoded[0][:len(encoded[0])-1])))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = p.Run(context.Background()); err == nil {
+			t.Fatalf("%s: expected an error decoding a truncated value", name)
+		}
+	}
+}
+
+func TestTOMLProtocol(t *testing.T) {
+	results := decodeAll(t, "toml", "title = \"a\"\n\n[owner]\nid = 1\n")
+	expect := []interface{}{
+		map[string]interface{}{"title": "a", "owner": map[string]interface{}{"id": int64(1)}},
+	}
+	if !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expected %v but got %v", expect, results)
+	}
+
+	encoded := encodeAll(t, "toml", expect[0])
+	if s := "title = \"a\"\n\n[owner]\n  id = 1\n"; len(encoded) != 1 || encoded[0] != s {
+		t.Fatalf("expected %q but got %q", s, encoded)
+	}
+}
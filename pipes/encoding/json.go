@@ -2,27 +2,99 @@ package encoding
 
 import (
 	"encoding/json"
+	"github.com/relvacode/pipe/console"
 	"io"
 )
 
 func init() {
-	Define(`json`, func() Protocol {
-		return JSONProtocol{}
+	Define(`json`, `JSON`, func(console *console.Command) Protocol {
+		return JSONProtocol{
+			Compact: console.Option("compact").Default(false).Describe("Encode without indentation").Bool(),
+			Array:   console.Option("array").Default(false).Describe("Encode every input into a single JSON array instead of a document for each input. The array is written as inputs arrive, and up to 1MB is buffered until the next pipe reads it").Bool(),
+		}
 	})
+	for _, name := range []string{`ndjson`, `jsonl`} {
+		Define(name, `newline-delimited JSON`, func(*console.Command) Protocol {
+			return NDJSONProtocol{}
+		})
+	}
 }
 
+// JSONProtocol encodes each object as an indented JSON document
 type JSONProtocol struct {
+	// Compact encodes objects without indentation
+	Compact *bool
+	// Array encodes every object into a single JSON array
+	Array *bool
+}
+
+func (p JSONProtocol) compact() bool {
+	return p.Compact != nil && *p.Compact
 }
 
-func (JSONProtocol) Encode(w io.Writer) Encoder {
+func (p JSONProtocol) Encode(w io.Writer) Encoder {
 	var e = json.NewEncoder(w)
-	e.SetIndent("", "  ")
+	if !p.compact() {
+		e.SetIndent("", "  ")
+	}
 
 	return func(x interface{}) error {
 		return e.Encode(x)
 	}
 }
 
+func (p JSONProtocol) Aggregate() bool {
+	return p.Array != nil && *p.Array
+}
+
+// EncodeAll writes each object as an item of a JSON array
+func (p JSONProtocol) EncodeAll(w io.Writer) (Encoder, func() error) {
+	var (
+		n      int
+		open   = "["
+		sep    = ","
+		indent = ""
+	)
+	if !p.compact() {
+		open, sep, indent = "[\n  ", ",\n  ", "\n"
+	}
+
+	encode := func(x interface{}) error {
+		var (
+			b   []byte
+			err error
+		)
+		if p.compact() {
+			b, err = json.Marshal(x)
+		} else {
+			b, err = json.MarshalIndent(x, "  ", "  ")
+		}
+		if err != nil {
+			return err
+		}
+
+		prefix := sep
+		if n == 0 {
+			prefix = open
+		}
+		n++
+		if _, err = io.WriteString(w, prefix); err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	end := func() error {
+		if n == 0 {
+			_, err := io.WriteString(w, "[]\n")
+			return err
+		}
+		_, err := io.WriteString(w, indent+"]\n")
+		return err
+	}
+	return encode, end
+}
+
 func (JSONProtocol) Decode(r io.Reader) Decoder {
 	e := json.NewDecoder(r)
 	return func() (interface{}, error) {
@@ -31,3 +103,15 @@ func (JSONProtocol) Decode(r io.Reader) Decoder {
 		return x, err
 	}
 }
+
+// NDJSONProtocol encodes each object as compact JSON on a single line
+type NDJSONProtocol struct {
+}
+
+func (NDJSONProtocol) Encode(w io.Writer) Encoder {
+	return json.NewEncoder(w).Encode
+}
+
+func (NDJSONProtocol) Decode(r io.Reader) Decoder {
+	return JSONProtocol{}.Decode(r)
+}
@@ -2,8 +2,13 @@ package encoding
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"github.com/relvacode/pipe"
 	"github.com/relvacode/pipe/e2e"
+	"io"
+	"io/ioutil"
+	"reflect"
 	"testing"
 )
 
@@ -35,3 +40,79 @@ func TestJsonPipe(t *testing.T) {
 		t.Fatalf("Expected 1 got %v", a)
 	}
 }
+
+// encodeAll runs script over objects, returning each reader it writes as a string
+func encodeAll(t *testing.T, script string, objects ...interface{}) []string {
+	var results []string
+	p, err := pipe.New(script, pipe.WithObjects(objects...), pipe.WithOutputFunc(func(_ context.Context, f *pipe.DataFrame) error {
+		b, err := ioutil.ReadAll(f.Object.(io.Reader))
+		results = append(results, string(b))
+		return err
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestJsonPipe_Encode(t *testing.T) {
+	objects := []interface{}{map[string]int{"a": 1}, []int{2}}
+	for script, expect := range map[string][]string{
+		"json":                           {"{\n  \"a\": 1\n}\n", "[\n  2\n]\n"},
+		"json -compact=true":             {"{\"a\":1}\n", "[2]\n"},
+		"ndjson":                         {"{\"a\":1}\n", "[2]\n"},
+		"json -array=true":               {"[\n  {\n    \"a\": 1\n  },\n  [\n    2\n  ]\n]\n"},
+		"json -array=true -compact=true": {"[{\"a\":1},[2]]\n"},
+	} {
+		results := encodeAll(t, script, objects...)
+		if !reflect.DeepEqual(results, expect) {
+			t.Fatalf("%s: expected %q but got %q", script, expect, results)
+		}
+	}
+}
+
+func TestJsonPipe_Array(t *testing.T) {
+	var objects = make([]interface{}, 1000)
+	for i := range objects {
+		objects[i] = i
+	}
+	results := encodeAll(t, "json -array=true -compact=true", objects...)
+	if len(results) != 1 {
+		t.Fatalf("expected a single array but got %d results", len(results))
+	}
+	var decoded []int
+	err := json.Unmarshal([]byte(results[0]), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(objects) || decoded[999] != 999 {
+		t.Fatalf("expected every input in the array but got %d items", len(decoded))
+	}
+}
+
+func TestJsonPipe_ArrayUnread(t *testing.T) {
+	// The array is buffered so that the pipeline can finish before the array is read
+	var r io.Reader
+	p, err := pipe.New("json -array=true -compact=true", pipe.WithObjects(1, 2, 3), pipe.WithOutputFunc(func(_ context.Context, f *pipe.DataFrame) error {
+		r = f.Object.(io.Reader)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "[1,2,3]\n"; string(b) != expect {
+		t.Fatalf("expected %q but got %q", expect, b)
+	}
+}
@@ -3,10 +3,12 @@ package encoding
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"github.com/relvacode/pipe"
 	"github.com/relvacode/pipe/console"
 	"github.com/relvacode/pipe/tap"
 	"io"
+	"sync"
 )
 
 type Decoder func() (interface{}, error)
@@ -19,12 +21,35 @@ type Protocol interface {
 	Encode(w io.Writer) Encoder
 }
 
-func Define(name string, p func() Protocol) {
+// An Aggregator is a protocol that can encode every object into a single document
+type Aggregator interface {
+	Protocol
+	// Aggregate returns true if every object should be encoded into a single reader instead of a reader for each object
+	Aggregate() bool
+	// EncodeAll returns an encoder that writes each object to w as part of one document,
+	// and a function that ends the document after the last object
+	EncodeAll(w io.Writer) (Encoder, func() error)
+}
+
+// Define registers a pipe for a protocol that decodes readers and encodes any other object.
+// The protocol is created with the command of each pipe so that it can describe its options.
+func Define(name, format string, p func(*console.Command) Protocol) {
 	pipe.Define(pipe.Pkg{
-		Name: name,
-		Constructor: func(_ *console.Command) pipe.Pipe {
+		Name:        name,
+		Description: fmt.Sprintf("Decode %[1]s from every reader, or encode any other input as %[1]s", format),
+		Examples: []pipe.Example{
+			{
+				Description: fmt.Sprintf("Decode %s files", format),
+				Script:      fmt.Sprintf("open *.%s :: %s", name, name),
+			},
+			{
+				Description: fmt.Sprintf("Encode the name of each file as %s", format),
+				Script:      fmt.Sprintf("open * :: select {name: this.Name} :: %s", name),
+			},
+		},
+		Constructor: func(console *console.Command) pipe.Pipe {
 			return &Pipe{
-				Protocol: p(),
+				Protocol: p(console),
 			}
 		},
 	})
@@ -63,9 +88,123 @@ func (p Pipe) EncodeProtocol(x interface{}, stream pipe.Stream) error {
 	return stream.Write(nil, &b)
 }
 
+// AggregateBuffer is how much of an aggregated document is kept in memory waiting to be read
+// before encoding blocks until it is read
+const AggregateBuffer = 1 << 20
+
+// aggregateBuffer is a pipe from an encoder to a reader that buffers up to AggregateBuffer bytes,
+// so that encoding doesn't wait for the reader until the buffer is full
+type aggregateBuffer struct {
+	mtx    sync.Mutex
+	cond   *sync.Cond
+	b      bytes.Buffer
+	err    error // the error returned to the reader once the buffer is empty
+	closed bool  // the reader has been closed
+}
+
+func newAggregateBuffer() *aggregateBuffer {
+	var a = new(aggregateBuffer)
+	a.cond = sync.NewCond(&a.mtx)
+	return a
+}
+
+func (a *aggregateBuffer) Write(p []byte) (int, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	var n int
+	for len(p) > 0 {
+		for a.b.Len() >= AggregateBuffer && !a.closed {
+			a.cond.Wait()
+		}
+		if a.closed {
+			return n, io.ErrClosedPipe
+		}
+		c := len(p)
+		if space := AggregateBuffer - a.b.Len(); c > space {
+			c = space
+		}
+		a.b.Write(p[:c])
+		n, p = n+c, p[c:]
+		a.cond.Broadcast()
+	}
+	return n, nil
+}
+
+func (a *aggregateBuffer) Read(p []byte) (int, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for a.b.Len() == 0 && a.err == nil && !a.closed {
+		a.cond.Wait()
+	}
+	if a.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if a.b.Len() == 0 {
+		return 0, a.err
+	}
+	n, _ := a.b.Read(p)
+	a.cond.Broadcast()
+	return n, nil
+}
+
+// Close stops reading, so that any more writes fail
+func (a *aggregateBuffer) Close() error {
+	a.mtx.Lock()
+	a.closed = true
+	a.cond.Broadcast()
+	a.mtx.Unlock()
+	return nil
+}
+
+// CloseWithError ends writing, so that reads return err, or io.EOF if nil, once the buffer is empty
+func (a *aggregateBuffer) CloseWithError(err error) {
+	if err == nil {
+		err = io.EOF
+	}
+	a.mtx.Lock()
+	a.err = err
+	a.cond.Broadcast()
+	a.mtx.Unlock()
+}
+
+// aggregate is a single reader that objects are encoded into as they are read
+type aggregate struct {
+	w      *aggregateBuffer
+	encode Encoder
+	end    func() error
+}
+
+func newAggregate(a Aggregator) (*aggregate, io.Reader) {
+	w := newAggregateBuffer()
+	encode, end := a.EncodeAll(w)
+	return &aggregate{
+		w:      w,
+		encode: encode,
+		end:    end,
+	}, w
+}
+
+// close ends the document, or closes the reader with err if not nil
+func (a *aggregate) close(err error) error {
+	if err == nil {
+		err = a.end()
+	}
+	a.w.CloseWithError(err)
+	return err
+}
+
+// Go decodes each reader and encodes any other object.
+// If the protocol aggregates objects then a single reader is written when the first object is encoded,
+// and each object after it is encoded into that reader as it is read.
+// Up to AggregateBuffer bytes are buffered waiting to be read, after which the pipe waits for the reader to be read,
+// so a pipe after it that waits for the end of its input before reading the reader stops the pipeline once the buffer is full.
 func (p Pipe) Go(_ context.Context, stream pipe.Stream) error {
+	var agg *aggregate
 	for {
 		f, err := stream.Read(nil)
+		if err == io.EOF && agg != nil {
+			return agg.close(nil)
+		}
 		if err != nil {
 			return err
 		}
@@ -76,10 +215,25 @@ func (p Pipe) Go(_ context.Context, stream pipe.Stream) error {
 			_ = tap.Close(x)
 
 		default:
-			err = p.EncodeProtocol(x, stream)
+			a, ok := p.Protocol.(Aggregator)
+			if !ok || !a.Aggregate() {
+				err = p.EncodeProtocol(x, stream)
+				break
+			}
+			if agg == nil {
+				var r io.Reader
+				agg, r = newAggregate(a)
+				err = stream.Write(nil, r)
+			}
+			if err == nil {
+				err = agg.encode(x)
+			}
 		}
 
 		if err != nil {
+			if agg != nil {
+				_ = agg.close(err)
+			}
 			return err
 		}
 	}
@@ -0,0 +1,35 @@
+package encoding
+
+import (
+	"github.com/BurntSushi/toml"
+	"github.com/relvacode/pipe/console"
+	"io"
+)
+
+func init() {
+	Define(`toml`, `TOML`, func(*console.Command) Protocol {
+		return TOMLProtocol{}
+	})
+}
+
+// TOMLProtocol decodes each reader as a single TOML document and encodes maps and structs as TOML
+type TOMLProtocol struct {
+}
+
+func (TOMLProtocol) Encode(w io.Writer) Encoder {
+	return toml.NewEncoder(w).Encode
+}
+
+func (TOMLProtocol) Decode(r io.Reader) Decoder {
+	var done bool
+	return func() (interface{}, error) {
+		if done {
+			return nil, io.EOF
+		}
+		done = true
+
+		var x map[string]interface{}
+		_, err := toml.NewDecoder(r).Decode(&x)
+		return x, err
+	}
+}
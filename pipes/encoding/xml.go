@@ -0,0 +1,326 @@
+package encoding
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/console"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	Define(`xml`, `XML`, func(console *console.Command) Protocol {
+		return XMLProtocol{
+			Path: console.Option("path").Default("").Describe("Decode each element matching a path such as //item or /rss/channel/item instead of whole documents").String(),
+			Root: console.Option("root").Default("").Describe("The name of the root element to encode each input as").String(),
+		}
+	})
+}
+
+const (
+	// xmlAttr prefixes the name of an attribute
+	xmlAttr = "@"
+	// xmlText is the key of the text of an element that also has attributes or children
+	xmlText = "#text"
+	// xmlRoot is the name of the root element of encoded objects that aren't a map with a single key
+	xmlRoot = "root"
+	// xmlItem is the name of each element of an encoded list that isn't the value of a key
+	xmlItem = "item"
+)
+
+// XMLProtocol decodes XML into maps and encodes maps as XML.
+//
+// Each element is decoded as a map of its attributes, prefixed with @, and its children by name.
+// Children with the same name are decoded as a list in the order they appear.
+// Text is decoded as #text, or as the value of the element itself if it has no attributes or children.
+// Empty elements are decoded as nil and whitespace around text is removed.
+// Namespaces are ignored, elements and attributes are named by their local name.
+//
+// Without a path each document is decoded as a map of the name of its root element to its value.
+// With a path each matching element is decoded by itself as soon as it ends, so the whole document is never held in memory.
+//
+// Encoding is the reverse, where a map with a single key is encoded with that key as its root element.
+// Any other object is encoded as an element named root, or the name given by Root, and each item of a list as an element named item.
+type XMLProtocol struct {
+	Path *string
+	Root *string
+}
+
+// xmlPath matches the names of the elements leading to an element
+type xmlPath struct {
+	names []string
+	// anywhere matches elements at any depth that end with names
+	anywhere bool
+}
+
+// parseXMLPath parses an absolute path such as /a/b or a relative path such as //b or b.
+// A * matches an element of any name.
+func parseXMLPath(s string) (*xmlPath, error) {
+	var p = &xmlPath{
+		anywhere: !strings.HasPrefix(s, "/") || strings.HasPrefix(s, "//"),
+		names:    strings.Split(strings.TrimLeft(s, "/"), "/"),
+	}
+	for _, n := range p.names {
+		if n == "" {
+			return nil, errors.Errorf("unsupported path %q, expected names separated by /", s)
+		}
+	}
+	return p, nil
+}
+
+func (p *xmlPath) match(stack []string) bool {
+	if len(stack) < len(p.names) || (!p.anywhere && len(stack) != len(p.names)) {
+		return false
+	}
+	stack = stack[len(stack)-len(p.names):]
+	for i, n := range p.names {
+		if n != "*" && n != stack[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// addXML adds the value of the child element k to m, making a list if k is repeated
+func addXML(m map[string]interface{}, k string, v interface{}) {
+	existing, ok := m[k]
+	if !ok {
+		m[k] = v
+		return
+	}
+	if list, ok := existing.([]interface{}); ok {
+		m[k] = append(list, v)
+		return
+	}
+	m[k] = []interface{}{existing, v}
+}
+
+// decodeXML decodes the content of the element started by start
+func decodeXML(d *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	var (
+		m    = make(map[string]interface{})
+		text strings.Builder
+	)
+	for _, a := range start.Attr {
+		if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+			continue
+		}
+		m[xmlAttr+a.Name.Local] = a.Value
+	}
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch x := t.(type) {
+		case xml.StartElement:
+			v, err := decodeXML(d, x)
+			if err != nil {
+				return nil, err
+			}
+			addXML(m, x.Name.Local, v)
+		case xml.CharData:
+			text.Write(x)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			switch {
+			case len(m) > 0 && s != "":
+				m[xmlText] = s
+			case len(m) == 0 && s != "":
+				return s, nil
+			case len(m) == 0:
+				return nil, nil
+			}
+			return m, nil
+		}
+	}
+}
+
+func (p XMLProtocol) Decode(r io.Reader) Decoder {
+	var (
+		d     = xml.NewDecoder(r)
+		stack []string
+		path  *xmlPath
+		err   error
+	)
+	if p.Path != nil && *p.Path != "" {
+		path, err = parseXMLPath(*p.Path)
+	}
+	return func() (interface{}, error) {
+		if err != nil {
+			return nil, err
+		}
+		for {
+			t, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			switch x := t.(type) {
+			case xml.StartElement:
+				stack = append(stack, x.Name.Local)
+				if path == nil || path.match(stack) {
+					v, err := decodeXML(d, x)
+					stack = stack[:len(stack)-1]
+					if err != nil || path != nil {
+						return v, err
+					}
+					return map[string]interface{}{x.Name.Local: v}, nil
+				}
+			case xml.EndElement:
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+// xmlMap returns x as a map of string keys if x is a map
+func xmlMap(x interface{}) (map[string]interface{}, bool) {
+	var rv = reflect.Indirect(reflect.ValueOf(x))
+	if rv.Kind() != reflect.Map {
+		return nil, false
+	}
+	var m = make(map[string]interface{}, rv.Len())
+	for _, k := range rv.MapKeys() {
+		m[fmt.Sprint(k.Interface())] = rv.MapIndex(k).Interface()
+	}
+	return m, true
+}
+
+// isXMLList returns true if x should be encoded as repeated elements
+func isXMLList(x interface{}) bool {
+	switch reflect.Indirect(reflect.ValueOf(x)).Kind() {
+	case reflect.Slice, reflect.Array:
+		_, bytes := x.([]byte)
+		return !bytes
+	}
+	return false
+}
+
+// xmlString formats x as the text of an element or attribute
+func xmlString(x interface{}) string {
+	switch v := x.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(x)
+}
+
+// encodeXML encodes x as an element named name, or as an element for each item if x is a list
+func encodeXML(e *xml.Encoder, name string, x interface{}) error {
+	if name == "" {
+		return errors.New("cannot encode an element without a name")
+	}
+	if isXMLList(x) {
+		rv := reflect.Indirect(reflect.ValueOf(x))
+		for i := 0; i < rv.Len(); i++ {
+			err := encodeXML(e, name, rv.Index(i).Interface())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var start = xml.StartElement{Name: xml.Name{Local: name}}
+	m, ok := xmlMap(x)
+	if !ok {
+		err := e.EncodeToken(start)
+		if err == nil && x != nil {
+			err = e.EncodeToken(xml.CharData(xmlString(x)))
+		}
+		if err != nil {
+			return err
+		}
+		return e.EncodeToken(start.End())
+	}
+
+	var keys = make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var children []string
+	for _, k := range keys {
+		switch {
+		case strings.HasPrefix(k, xmlAttr):
+			start.Attr = append(start.Attr, xml.Attr{
+				Name:  xml.Name{Local: strings.TrimPrefix(k, xmlAttr)},
+				Value: xmlString(m[k]),
+			})
+		case k != xmlText:
+			children = append(children, k)
+		}
+	}
+
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	if text, ok := m[xmlText]; ok {
+		err = e.EncodeToken(xml.CharData(xmlString(text)))
+		if err != nil {
+			return err
+		}
+	}
+	for _, k := range children {
+		err = encodeXML(e, k, m[k])
+		if err != nil {
+			return errors.Wrap(err, name)
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func (p XMLProtocol) Encode(w io.Writer) Encoder {
+	return func(x interface{}) error {
+		var name string
+		if p.Root != nil {
+			name = *p.Root
+		}
+		if m, ok := xmlMap(x); ok && name == "" && len(m) == 1 {
+			for k, v := range m {
+				if !isXMLList(v) {
+					name, x = k, v
+				}
+			}
+		}
+		if name == "" {
+			name = xmlRoot
+		}
+		if isXMLList(x) {
+			x = map[string]interface{}{xmlItem: x}
+		}
+
+		_, err := io.WriteString(w, xml.Header)
+		if err != nil {
+			return err
+		}
+		e := xml.NewEncoder(w)
+		e.Indent("", "  ")
+		err = encodeXML(e, name, x)
+		if err == nil {
+			err = e.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "\n")
+		return err
+	}
+}
@@ -0,0 +1,101 @@
+package encoding
+
+import (
+	"context"
+	"github.com/relvacode/pipe"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testXMLInput = `<?xml version="1.0"?>
+<rss xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <item id="1"><title>a</title><empty/></item>
+    <item id="2"><title>b</title><tag>x</tag><tag>y</tag></item>
+    <note lang="en">text</note>
+  </channel>
+</rss>`
+
+// decodeAll runs script over input, returning the objects it writes
+func decodeAll(t *testing.T, script, input string) []interface{} {
+	var results []interface{}
+	p, err := pipe.New(script, pipe.WithObjects(strings.NewReader(input)), pipe.WithOutputFunc(func(_ context.Context, f *pipe.DataFrame) error {
+		results = append(results, f.Object)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestXMLPipe_Decode(t *testing.T) {
+	var (
+		a = map[string]interface{}{"@id": "1", "title": "a", "empty": nil}
+		b = map[string]interface{}{"@id": "2", "title": "b", "tag": []interface{}{"x", "y"}}
+	)
+	expect := []interface{}{
+		map[string]interface{}{
+			"rss": map[string]interface{}{
+				"channel": map[string]interface{}{
+					"item": []interface{}{a, b},
+					"note": map[string]interface{}{"@lang": "en", "#text": "text"},
+				},
+			},
+		},
+	}
+	if results := decodeAll(t, "xml", testXMLInput); !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expected %v but got %v", expect, results)
+	}
+
+	for _, path := range []string{"//item", "item", "/rss/channel/item", "//channel/*"} {
+		expect := []interface{}{a, b}
+		if path == "//channel/*" {
+			expect = append(expect, map[string]interface{}{"@lang": "en", "#text": "text"})
+		}
+		if results := decodeAll(t, "xml -path "+path, testXMLInput); !reflect.DeepEqual(results, expect) {
+			t.Fatalf("%s: expected %v but got %v", path, expect, results)
+		}
+	}
+	if results := decodeAll(t, "xml -path /channel/item", testXMLInput); len(results) != 0 {
+		t.Fatalf("expected an absolute path to only match from the root but got %v", results)
+	}
+}
+
+func TestXMLPipe_Encode(t *testing.T) {
+	objects := []interface{}{
+		map[string]interface{}{
+			"item": map[string]interface{}{"@id": 1, "title": "a & b", "tag": []string{"x", "y"}},
+		},
+		[]int{1, 2},
+	}
+	expect := []string{
+		`<?xml version="1.0" encoding="UTF-8"?>
+<item id="1">
+  <tag>x</tag>
+  <tag>y</tag>
+  <title>a &amp; b</title>
+</item>
+`,
+		`<?xml version="1.0" encoding="UTF-8"?>
+<root>
+  <item>1</item>
+  <item>2</item>
+</root>
+`,
+	}
+	results := encodeAll(t, "xml", objects...)
+	if !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expected %q but got %q", expect, results)
+	}
+
+	results = encodeAll(t, "xml -root feed", map[string]interface{}{"#text": "x"})
+	if expect := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<feed>x</feed>\n"; len(results) != 1 || results[0] != expect {
+		t.Fatalf("expected %q but got %q", expect, results)
+	}
+}
@@ -2,17 +2,23 @@ package encoding
 
 import (
 	"bytes"
+	"github.com/relvacode/pipe/console"
 	"gopkg.in/yaml.v2"
 	"io"
 )
 
 func init() {
-	Define(`yaml`, func() Protocol {
-		return YAMLProtocol{}
+	Define(`yaml`, `YAML`, func(console *console.Command) Protocol {
+		return YAMLProtocol{
+			Stream: console.Option("stream").Default(false).Describe("Encode every input as a document of a single multi-document stream. The stream is written as inputs arrive, and up to 1MB is buffered until the next pipe reads it").Bool(),
+		}
 	})
 }
 
+// YAMLProtocol decodes each document of a YAML stream and encodes each object as a document
 type YAMLProtocol struct {
+	// Stream encodes every object into a single stream of documents separated by ---
+	Stream *bool
 }
 
 func (YAMLProtocol) Encode(w io.Writer) Encoder {
@@ -27,21 +33,26 @@ func (YAMLProtocol) Encode(w io.Writer) Encoder {
 	}
 }
 
+func (p YAMLProtocol) Aggregate() bool {
+	return p.Stream != nil && *p.Stream
+}
+
+// EncodeAll writes each object as a document separated by ---
+func (YAMLProtocol) EncodeAll(w io.Writer) (Encoder, func() error) {
+	e := yaml.NewEncoder(w)
+	return e.Encode, e.Close
+}
+
+// Decode reads each document as it is needed, skipping empty documents
 func (YAMLProtocol) Decode(r io.Reader) Decoder {
-	var b bytes.Buffer
+	d := yaml.NewDecoder(r)
 	return func() (interface{}, error) {
-		defer b.Reset()
-
-		n, err := io.Copy(&b, r)
-		if n == 0 {
-			return nil, io.EOF
-		}
-		if err != nil {
-			return nil, err
+		for {
+			var x interface{}
+			err := d.Decode(&x)
+			if err != nil || x != nil {
+				return x, err
+			}
 		}
-
-		var x interface{}
-		err = yaml.Unmarshal(b.Bytes(), &x)
-		return x, err
 	}
 }
@@ -0,0 +1,66 @@
+package encoding
+
+import (
+	"context"
+	"github.com/relvacode/pipe"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestYamlPipe_Decode(t *testing.T) {
+	var (
+		r, w   = io.Pipe()
+		frames = make(chan *pipe.DataFrame)
+	)
+	go func() {
+		_, _ = io.WriteString(w, "a: 1\n---\n---\nb: 2\n---\n")
+		// Wait until the first document is decoded before ending the stream
+		<-frames
+		_, _ = io.WriteString(w, "c: 3\n")
+		_ = w.Close()
+	}()
+
+	var results []interface{}
+	p, err := pipe.New("yaml", pipe.WithObjects(r), pipe.WithOutputFunc(func(_ context.Context, f *pipe.DataFrame) error {
+		if len(results) == 0 {
+			select {
+			case frames <- f:
+			case <-time.After(5 * time.Second):
+				t.Error("expected the first document to be decoded before the end of the stream")
+			}
+		}
+		results = append(results, f.Object)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []interface{}{
+		map[interface{}]interface{}{"a": 1},
+		map[interface{}]interface{}{"b": 2},
+		map[interface{}]interface{}{"c": 3},
+	}
+	if !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expected %v but got %v", expect, results)
+	}
+}
+
+func TestYamlPipe_Encode(t *testing.T) {
+	objects := []interface{}{map[string]int{"a": 1}, []int{2}}
+	for script, expect := range map[string][]string{
+		"yaml":              {"a: 1\n", "- 2\n"},
+		"yaml -stream=true": {"a: 1\n---\n- 2\n"},
+	} {
+		results := encodeAll(t, script, objects...)
+		if !reflect.DeepEqual(results, expect) {
+			t.Fatalf("%s: expected %q but got %q", script, expect, results)
+		}
+	}
+}
@@ -11,14 +11,24 @@ func init() {
 	pipe.Define(
 		Expr(
 			"select",
+			"Write the result of an expression for every input",
+			pipe.Example{
+				Description: "Get the id of every JSON object",
+				Script:      "open *.json :: json :: select this.id",
+			},
 			func(f *pipe.DataFrame, x interface{}, stream pipe.Stream) error {
 				return stream.Write(nil, x)
 			},
 		))
 
-	pipe.Define(
+	pipe.Define(passthrough(
 		Expr(
 			"test",
+			"Fail unless an expression is true for every input, writing each input",
+			pipe.Example{
+				Description: "Check that every JSON file has an id",
+				Script:      "open *.json :: json :: test this.id != nil",
+			},
 			func(f *pipe.DataFrame, x interface{}, stream pipe.Stream) error {
 				b, ok := x.(bool)
 				if !ok {
@@ -29,11 +39,16 @@ func init() {
 				}
 				return stream.Write(nil, f.Object)
 			},
-		))
+		)))
 
-	pipe.Define(
+	pipe.Define(passthrough(
 		Expr(
 			"if",
+			"Write only the inputs for which an expression is true",
+			pipe.Example{
+				Description: "Decode all non-empty JSON files",
+				Script:      "open *.json :: if this.Size > 0 :: json",
+			},
 			func(f *pipe.DataFrame, x interface{}, stream pipe.Stream) error {
 				b, ok := x.(bool)
 				if !ok {
@@ -44,7 +59,13 @@ func init() {
 				}
 				return nil
 			},
-		))
+		)))
+}
+
+// passthrough marks pkg as writing the same kinds of object that it reads
+func passthrough(pkg pipe.Pkg) pipe.Pkg {
+	pkg.Output = []pipe.Kind{pipe.Same}
+	return pkg
 }
 
 // ExprEvalFunc is a function called after calling an expression query.
@@ -53,12 +74,14 @@ type ExprEvalFunc func(*pipe.DataFrame, interface{}, pipe.Stream) error
 
 // Expr is a function that constructs a module definition for a JQ style query with
 // additional logic applied to the return value.
-func Expr(name string, f ExprEvalFunc) pipe.Pkg {
+func Expr(name, description string, example pipe.Example, f ExprEvalFunc) pipe.Pkg {
 	return pipe.Pkg{
-		Name: name,
+		Name:        name,
+		Description: description,
+		Examples:    []pipe.Example{example},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return &ExprPipe{
-				e: console.Any().Expression(),
+				e: console.Any().Describe("The expression to evaluate").Expression(),
 				f: f,
 			}
 		},
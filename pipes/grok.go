@@ -12,10 +12,19 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "grok",
+		Name:        "grok",
+		Description: "Parse every line of the input using a grok pattern, writing the named captures of each matching line",
+		Input:       []pipe.Kind{pipe.Reader, pipe.String},
+		Output:      []pipe.Kind{pipe.Map},
+		Examples: []pipe.Example{
+			{
+				Description: "Get the client of each request in an access log",
+				Script:      "open access.log :: grok %{COMMONAPACHELOG} :: print {{this.clientip}}",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return &GrokPipe{
-				pattern: console.Any().String(),
+				pattern: console.Any().Describe("The grok pattern").String(),
 			}
 		},
 	})
@@ -12,10 +12,19 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "http",
+		Name:        "http",
+		Description: "Listen for HTTP requests, writing every request received",
+		Input:       []pipe.Kind{pipe.Nothing},
+		Output:      []pipe.Kind{pipe.Reader},
+		Examples: []pipe.Example{
+			{
+				Description: "Print the path of every request",
+				Script:      "http :8080 :: print {{this.Path}}",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return &HTTPServerPipe{
-				address: console.Arg(0).Default("127.0.0.1:8080").String(),
+				address: console.Arg(0).Default("127.0.0.1:8080").Describe("The address to listen on").String(),
 			}
 		},
 	})
@@ -41,6 +50,17 @@ func (r *Request) Read(b []byte) (int, error) {
 	return r.body.Read(b)
 }
 
+// Duplicate returns n copies of this request with each containing the remaining body
+func (r *Request) Duplicate(n int) ([]interface{}, error) {
+	var copies = make([]interface{}, n)
+	for i := range copies {
+		c := *r
+		c.body = bytes.NewBuffer(append([]byte(nil), r.body.Bytes()...))
+		copies[i] = &c
+	}
+	return copies, nil
+}
+
 type HTTPServerPipe struct {
 	address *string
 }
@@ -82,6 +102,10 @@ func (p *HTTPServerPipe) Go(ctx context.Context, stream pipe.Stream) error {
 	case <-ctx.Done():
 		server.Shutdown(nil)
 		return nil
+	case <-pipe.Drained(ctx):
+		// Finish any requests in progress
+		server.Shutdown(ctx)
+		return nil
 	case err := <-cancel:
 		server.Shutdown(ctx)
 		return err
@@ -12,7 +12,16 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "buffer",
+		Name:        "buffer",
+		Description: "Read every input into memory",
+		Input:       []pipe.Kind{pipe.Reader, pipe.String},
+		Output:      []pipe.Kind{pipe.Reader},
+		Examples: []pipe.Example{
+			{
+				Description: "Read a response body so that it can be used more than once",
+				Script:      "url.get https://example.com :: buffer as page :: checksum.md5 :: print {{page}}",
+			},
+		},
 		Constructor: func(command *console.Command) pipe.Pipe {
 			return BufferPipe{}
 		},
@@ -12,10 +12,18 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "ask",
+		Name:        "ask",
+		Output:      []pipe.Kind{pipe.Same},
+		Description: "Ask a yes or no question for every input, writing the input if the answer is yes",
+		Examples: []pipe.Example{
+			{
+				Description: "Choose which files to remove",
+				Script:      "open *.tmp as f :: ask Remove {{f.Name}}? :: rm {{f.Name}}",
+			},
+		},
 		Constructor: func(command *console.Command) pipe.Pipe {
 			return AskPipe{
-				question: command.Any().Template(),
+				question: command.Any().Describe("The question to ask").Template(),
 			}
 		},
 	})
@@ -9,7 +9,15 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "flatten",
+		Name:        "flatten",
+		Description: "Write each item of every list on its own, writing any other input as it is",
+		Input:       []pipe.Kind{pipe.List, pipe.Any},
+		Examples: []pipe.Example{
+			{
+				Description: "Print each item of a JSON array",
+				Script:      "open items.json :: json :: flatten :: print {{this}}",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return FlattenPipe{}
 		},
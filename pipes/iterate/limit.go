@@ -8,10 +8,19 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "limit",
+		Name:        "limit",
+		Output:      []pipe.Kind{pipe.Same},
+		Description: "Write only the first inputs",
+		Stateful:    true,
+		Examples: []pipe.Example{
+			{
+				Description: "Print the first 10 lines of a file",
+				Script:      "open app.log :: split :: limit 10",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return LimitPipe{
-				Limit: console.Arg(0).Int(),
+				Limit: console.Arg(0).Describe("The number of inputs to write").Int(),
 			}
 		},
 	})
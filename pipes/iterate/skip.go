@@ -8,10 +8,19 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "skip",
+		Name:        "skip",
+		Output:      []pipe.Kind{pipe.Same},
+		Description: "Discard the first inputs, writing the rest",
+		Stateful:    true,
+		Examples: []pipe.Example{
+			{
+				Description: "Skip the header line of a file",
+				Script:      "open data.txt :: split :: skip 1",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return SkipPipe{
-				Skip: console.Arg(0).Int(),
+				Skip: console.Arg(0).Describe("The number of inputs to discard").Int(),
 			}
 		},
 	})
@@ -13,21 +13,38 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: pipe.Family("nats", "subscribe"),
+		Name:        pipe.Family("nats", "subscribe"),
+		Description: "Subscribe to a NATS subject, writing every message received",
+		Input:       []pipe.Kind{pipe.Nothing},
+		Output:      []pipe.Kind{pipe.Reader},
+		Examples: []pipe.Example{
+			{
+				Description: "Decode every message sent to the events subject",
+				Script:      "nats.subscribe nats://127.0.0.1:4222/events :: json",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return &NatsReceiverPipe{
 				NatsClient{
-					url: console.Arg(0).String(),
+					url: console.Arg(0).Describe("The URL of the server with the subject as its path").String(),
 				},
 			}
 		},
 	})
 	pipe.Define(pipe.Pkg{
-		Name: pipe.Family("nats", "publish"),
+		Name:        pipe.Family("nats", "publish"),
+		Description: "Publish every input to a NATS subject",
+		Output:      []pipe.Kind{pipe.Nothing},
+		Examples: []pipe.Example{
+			{
+				Description: "Publish every request received to the events subject",
+				Script:      "http :8080 :: nats.publish nats://127.0.0.1:4222/events",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return &NatsSenderPipe{
 				NatsClient{
-					url: console.Arg(0).String(),
+					url: console.Arg(0).Describe("The URL of the server with the subject as its path").String(),
 				},
 			}
 		},
@@ -80,6 +97,8 @@ func (p *NatsReceiverPipe) Go(ctx context.Context, stream pipe.Stream) error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-pipe.Drained(ctx):
+			return nil
 		case m := <-msg:
 			copied := make([]byte, len(m.Data))
 			copy(copied, m.Data)
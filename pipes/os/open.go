@@ -11,10 +11,18 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "open",
+		Name:        "open",
+		Description: "Open every file matching a glob pattern",
+		Output:      []pipe.Kind{pipe.Reader},
+		Examples: []pipe.Example{
+			{
+				Description: "Decode all JSON files in the current directory",
+				Script:      "open *.json :: json",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return &OpenPipe{
-				glob: console.Arg(0).Template(),
+				glob: console.Arg(0).Describe("The glob pattern of the files to open").Template(),
 			}
 		},
 	})
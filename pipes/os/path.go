@@ -11,10 +11,18 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "path",
+		Name:        "path",
+		Description: "Write every file and directory within a path",
+		Output:      []pipe.Kind{pipe.Reader},
+		Examples: []pipe.Example{
+			{
+				Description: "List every file within the current directory",
+				Script:      "path . :: print {{this.Name}}",
+			},
+		},
 		Constructor: func(command *console.Command) pipe.Pipe {
 			return PathPipe{
-				where: command.Arg(0).Template(),
+				where: command.Arg(0).Describe("The path to walk").Template(),
 			}
 		},
 	})
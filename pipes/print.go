@@ -9,10 +9,18 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "print",
+		Name:        "print",
+		Description: "Render a template for every input",
+		Output:      []pipe.Kind{pipe.String},
+		Examples: []pipe.Example{
+			{
+				Description: "Print the name of each user",
+				Script:      "open users.json :: json :: flatten as user :: print {{user.name}}",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return &PrintPipe{
-				Template: console.Any().Template(),
+				Template: console.Any().Describe("The template to render").Template(),
 			}
 		},
 	})
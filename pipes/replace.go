@@ -11,11 +11,20 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "replace",
+		Name:        "replace",
+		Description: "Replace all occurrences of some text in every input",
+		Input:       []pipe.Kind{pipe.Reader, pipe.String},
+		Output:      []pipe.Kind{pipe.Reader},
+		Examples: []pipe.Example{
+			{
+				Description: "Replace all tabs with spaces",
+				Script:      `open data.tsv :: replace "\t" " "`,
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return &ReplacePipe{
-				What: console.Arg(0).Template(),
-				With: console.Arg(1).Template(),
+				What: console.Arg(0).Describe("The text to search for").Template(),
+				With: console.Arg(1).Describe("The text to replace it with").Template(),
 			}
 		},
 	})
@@ -12,10 +12,23 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "split",
+		Name:        "split",
+		Description: "Split every input into parts separated by some text",
+		Input:       []pipe.Kind{pipe.Reader, pipe.String},
+		Output:      []pipe.Kind{pipe.String},
+		Examples: []pipe.Example{
+			{
+				Description: "Split a file into lines",
+				Script:      "open names.txt :: split",
+			},
+			{
+				Description: "Split a file by commas",
+				Script:      "open names.txt :: split ,",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return &SplitPipe{
-				Split: console.Arg(0).Default("\n").String(),
+				Split: console.Arg(0).Default("\n").Describe("The text that separates each part").String(),
 			}
 		},
 	})
@@ -9,26 +9,51 @@ import (
 
 func init() {
 	pipe.Define(pipe.Pkg{
-		Name: "every",
+		Name:        "every",
+		Description: "Write the current time at a regular interval",
+		Input:       []pipe.Kind{pipe.Nothing},
+		Output:      []pipe.Kind{pipe.Time},
+		Examples: []pipe.Example{
+			{
+				Description: "Check a website every minute",
+				Script:      "every 1m :: url.get https://example.com :: print {{this.StatusCode}}",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return EveryPipe{
-				Duration: console.Arg(0).Duration(),
+				Duration: console.Arg(0).Describe("The interval, such as 1s or 5m").Duration(),
 			}
 		},
 	})
 	pipe.Define(pipe.Pkg{
-		Name: "timeout",
+		Name:        "timeout",
+		Output:      []pipe.Kind{pipe.Same},
+		Description: "Write every input, stopping if no input is received in time",
+		Examples: []pipe.Example{
+			{
+				Description: "Stop once no messages have been received for 10 seconds",
+				Script:      "nats.subscribe nats://127.0.0.1:4222/events :: timeout 10s",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return TimeoutPipe{
-				Duration: console.Arg(0).Duration(),
+				Duration: console.Arg(0).Describe("How long to wait for each input").Duration(),
 			}
 		},
 	})
 	pipe.Define(pipe.Pkg{
-		Name: "delay",
+		Name:        "delay",
+		Output:      []pipe.Kind{pipe.Same},
+		Description: "Wait before writing every input",
+		Examples: []pipe.Example{
+			{
+				Description: "Request each URL no more than once a second",
+				Script:      "open urls.txt :: split :: delay 1s :: url.get {{this}}",
+			},
+		},
 		Constructor: func(console *console.Command) pipe.Pipe {
 			return DelayPipe{
-				Duration: console.Arg(0).Duration(),
+				Duration: console.Arg(0).Describe("How long to wait").Duration(),
 			}
 		},
 	})
@@ -46,6 +71,8 @@ func (p EveryPipe) Go(ctx context.Context, stream pipe.Stream) error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-pipe.Drained(ctx):
+			return nil
 		case t := <-ticker.C:
 			err := stream.Write(nil, t)
 			if err != nil {
@@ -1,12 +1,15 @@
 package pipes
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"github.com/pkg/errors"
 	"github.com/relvacode/pipe"
 	"github.com/relvacode/pipe/console"
 	"github.com/relvacode/pipe/tap"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strings"
 )
@@ -24,13 +27,21 @@ func init() {
 	for i := range methods {
 		method := methods[i]
 		pipe.Define(pipe.Pkg{
-			Name: pipe.Family("url", strings.ToLower(method)),
+			Name:        pipe.Family("url", strings.ToLower(method)),
+			Description: fmt.Sprintf("Send an HTTP %s request for every input and write the response", method),
+			Output:      []pipe.Kind{pipe.Reader},
+			Examples: []pipe.Example{
+				{
+					Description: fmt.Sprintf("Send a %s request to each URL in a file", method),
+					Script:      fmt.Sprintf("open urls.txt :: split :: url.%s {{this}}", strings.ToLower(method)),
+				},
+			},
 			Constructor: func(console *console.Command) pipe.Pipe {
 				return &URLPipe{
 					method:  method,
-					headers: console.Option("header").Default(nil).Map(),
-					body:    console.Option("body").Default(false).Bool(),
-					url:     console.Arg(0).Template(),
+					headers: console.Option("header").Default(nil).Describe("A header to send with the request, given as name:value").Map(),
+					body:    console.Option("body").Default(false).Describe("Send the input as the body of the request").Bool(),
+					url:     console.Arg(0).Describe("The URL to request").Template(),
 				}
 			},
 		})
@@ -53,6 +64,24 @@ func (r *Response) Close() error {
 	return r.Body.Close()
 }
 
+// Duplicate reads the response body into memory and returns n copies of this response
+func (r *Response) Duplicate(n int) ([]interface{}, error) {
+	b, err := ioutil.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var copies = make([]interface{}, n)
+	for i := range copies {
+		copies[i] = &Response{
+			StatusCode: r.StatusCode,
+			Headers:    r.Headers,
+			Body:       ioutil.NopCloser(bytes.NewReader(b)),
+		}
+	}
+	return copies, nil
+}
+
 // URLPipe calls a URL and emits a Response to the stream
 type URLPipe struct {
 	method  string
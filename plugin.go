@@ -0,0 +1,268 @@
+package pipe
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/console"
+	"github.com/relvacode/pipe/tap"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A plugin is a program that runs as a pipe, reading requests and writing responses as newline-delimited JSON.
+//
+// When loaded a plugin is sent a single describe request, to which it responds with a description of itself.
+// When run as a pipe the plugin is sent a frame request for each frame read by the pipe.
+// For each frame the plugin responds with a write response for each object it writes followed by a done response,
+// or with an error response to stop the pipeline.
+// Once all frames have been sent stdin is closed and the plugin should exit.
+const (
+	pluginDescribe = "describe"
+	pluginFrame    = "frame"
+	pluginWrite    = "write"
+	pluginDone     = "done"
+	pluginError    = "error"
+)
+
+// PluginDescribeTimeout is how long to wait for a plugin to describe itself
+var PluginDescribeTimeout = 5 * time.Second
+
+// pluginRequest is a request sent to a plugin
+type pluginRequest struct {
+	Type string `json:"type"`
+	// Args are the arguments of the pipe rendered for this frame
+	Args string `json:"args,omitempty"`
+	// Tag is the tag of the frame, if any
+	Tag    string      `json:"tag,omitempty"`
+	Index  uint64      `json:"index"`
+	Object interface{} `json:"object"`
+	// Stack are the tagged objects of the frame.
+	// Readers are only sent as objects, in the stack they are null.
+	Stack map[string]interface{} `json:"stack,omitempty"`
+}
+
+// pluginResponse is a response written by a plugin
+type pluginResponse struct {
+	Type string `json:"type"`
+
+	// Object is the object of a write response
+	Object interface{} `json:"object"`
+	// Error is the message of an error response
+	Error string `json:"error"`
+
+	// Name, Description, Arguments, Input, Output and Examples are the description of a plugin in a describe response
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Arguments   string    `json:"arguments"`
+	Input       []Kind    `json:"input"`
+	Output      []Kind    `json:"output"`
+	Examples    []Example `json:"examples"`
+}
+
+// pluginProcess is a running plugin
+type pluginProcess struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+func startPlugin(ctx context.Context, path string) (*pluginProcess, error) {
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = os.Environ()
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &pluginProcess{
+		cmd: cmd,
+		in:  in,
+		enc: json.NewEncoder(in),
+		dec: json.NewDecoder(bufio.NewReader(out)),
+	}, nil
+}
+
+// receive reads the next response from the plugin
+func (p *pluginProcess) receive() (*pluginResponse, error) {
+	var r pluginResponse
+	err := p.dec.Decode(&r)
+	if err == io.EOF {
+		return nil, errors.New("plugin exited before responding")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read response")
+	}
+	if r.Type == pluginError {
+		return nil, errors.New(r.Error)
+	}
+	return &r, nil
+}
+
+// wait closes the input to the plugin and waits for it to exit
+func (p *pluginProcess) wait() error {
+	_ = p.in.Close()
+	return p.cmd.Wait()
+}
+
+// kill stops the plugin without waiting for it to finish
+func (p *pluginProcess) kill() {
+	_ = p.in.Close()
+	_ = p.cmd.Process.Kill()
+	_ = p.cmd.Wait()
+}
+
+// NewPluginPkg creates a pipe for the plugin at path using the description it responds with.
+// If the plugin doesn't give a name then the name of the file without its extension is used.
+func NewPluginPkg(path string) (Pkg, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), PluginDescribeTimeout)
+	defer cancel()
+
+	p, err := startPlugin(ctx, path)
+	if err != nil {
+		return Pkg{}, err
+	}
+	err = p.enc.Encode(pluginRequest{Type: pluginDescribe})
+	if err != nil {
+		p.kill()
+		return Pkg{}, errors.Wrap(err, "describe")
+	}
+	d, err := p.receive()
+	if err != nil {
+		p.kill()
+		return Pkg{}, errors.Wrap(err, "describe")
+	}
+	if err := p.wait(); err != nil {
+		return Pkg{}, errors.Wrap(err, "describe")
+	}
+	if d.Type != pluginDescribe {
+		return Pkg{}, errors.Errorf("describe: expected a %s response but got %q", pluginDescribe, d.Type)
+	}
+
+	if d.Name == "" {
+		d.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if d.Arguments == "" {
+		d.Arguments = "The arguments given to the plugin for each input"
+	}
+	return Pkg{
+		Name:        d.Name,
+		Description: d.Description,
+		Input:       d.Input,
+		Output:      d.Output,
+		Examples:    d.Examples,
+		Plugin:      path,
+		Constructor: func(console *console.Command) Pipe {
+			return &PluginPipe{
+				path: path,
+				args: console.Any().Default("").Describe(d.Arguments).Template(),
+			}
+		},
+	}, nil
+}
+
+// PluginPipe sends each frame to a plugin and writes each object it responds with.
+// The plugin is started once and runs until all frames have been read.
+type PluginPipe struct {
+	path string
+	args *tap.Template
+}
+
+// request creates the frame request for f
+func (p *PluginPipe) request(f *DataFrame) (*pluginRequest, error) {
+	args, err := p.args.Render(f.Context())
+	if err != nil {
+		return nil, err
+	}
+	object, err := normalize(f.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	var r = &pluginRequest{
+		Type:   pluginFrame,
+		Args:   args,
+		Index:  f.Index,
+		Object: object,
+	}
+	if f.Tag != nil {
+		r.Tag = string(*f.Tag)
+	}
+	if len(f.Stack) > 0 {
+		r.Stack = make(map[string]interface{}, len(f.Stack))
+		for k, v := range f.Stack {
+			r.Stack[k], err = normalizeValue(v, false)
+			if err != nil {
+				return nil, errors.Wrap(err, k)
+			}
+		}
+	}
+	return r, nil
+}
+
+// frame sends f to the plugin and writes each object it responds with until it is done
+func (p *PluginPipe) frame(plugin *pluginProcess, f *DataFrame, stream Stream) error {
+	r, err := p.request(f)
+	if err != nil {
+		return err
+	}
+	err = plugin.enc.Encode(r)
+	if err != nil {
+		return errors.Wrap(err, "send frame")
+	}
+
+	for {
+		resp, err := plugin.receive()
+		if err != nil {
+			return err
+		}
+		switch resp.Type {
+		case pluginWrite:
+			err = stream.Write(nil, resp.Object)
+			if err != nil {
+				return err
+			}
+		case pluginDone:
+			return nil
+		default:
+			return errors.Errorf("unexpected %q response", resp.Type)
+		}
+	}
+}
+
+func (p *PluginPipe) Go(ctx context.Context, stream Stream) error {
+	plugin, err := startPlugin(ctx, p.path)
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("started plugin %s", p.path)
+
+	for {
+		f, err := stream.Read(nil)
+		if err == io.EOF {
+			return errors.Wrap(plugin.wait(), p.path)
+		}
+		if err == nil {
+			err = p.frame(plugin, f, stream)
+		}
+		if err != nil {
+			plugin.kill()
+			return errors.Wrap(err, p.path)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package pipe
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testPlugin responds to each frame by writing the request it was sent
+const testPlugin = `#!/bin/sh
+while IFS= read -r line; do
+	case "$line" in
+	*'"type":"describe"'*)
+		echo '{"type":"describe","description":"Write each request","arguments":"Anything","output":["map"]}' ;;
+	*'"args":"fail"'*)
+		echo '{"type":"error","error":"asked to fail"}' ;;
+	*)
+		printf '{"type":"write","object":%s}\n{"type":"done"}\n' "$line" ;;
+	esac
+done
+`
+
+// writePlugin writes the test plugin into a temporary directory, returning its path
+func writePlugin(t *testing.T) string {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found in PATH")
+	}
+	dir, err := ioutil.TempDir("", "pipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "requests.sh")
+	err = ioutil.WriteFile(path, []byte(testPlugin), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewPluginPkg(t *testing.T) {
+	path := writePlugin(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	pkg, err := NewPluginPkg(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Name != "requests" || pkg.Description != "Write each request" || pkg.Plugin != path {
+		t.Fatalf("unexpected package %+v", pkg)
+	}
+	if !reflect.DeepEqual(pkg.Output, []Kind{Map}) {
+		t.Fatalf("expected the plugin to write maps but got %v", pkg.Output)
+	}
+	if help := Help(pkg); !strings.Contains(help, "Anything") {
+		t.Fatalf("expected the help to describe the arguments of the plugin but got\n%s", help)
+	}
+}
+
+func TestPluginPipe(t *testing.T) {
+	path := writePlugin(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	pkg, err := NewPluginPkg(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c = make(chan *DataFrame, 10)
+	p, err := New("render {{this}} as x :: render {{x}}! as y :: requests {{x}}",
+		WithRegistry(renderRegistry), WithPkg(pkg), WithObjects("a", "b"), WithOutputChan(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, x := range []string{"a", "b"} {
+		expect := map[string]interface{}{
+			"type":   "frame",
+			"args":   x,
+			"tag":    "y",
+			"index":  float64(i),
+			"object": x + "!",
+			"stack":  map[string]interface{}{"x": x},
+		}
+		f := <-c
+		if !reflect.DeepEqual(f.Object, expect) {
+			t.Fatalf("expected %v but got %v", expect, f.Object)
+		}
+		if f.Stack["y"] != x+"!" {
+			t.Fatalf("expected the written object to have its input in the stack but got %v", f.Stack)
+		}
+	}
+
+	p, err = New("requests fail", WithPkg(pkg), WithObjects("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "asked to fail") {
+		t.Fatalf("expected the plugin to fail but got %v", err)
+	}
+}
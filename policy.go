@@ -0,0 +1,255 @@
+package pipe
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/tap"
+	"github.com/sirupsen/logrus"
+	"io"
+	"time"
+)
+
+// OnError is what to do with a frame that a pipe failed to process
+type OnError string
+
+const (
+	// Fail stops the whole pipeline
+	Fail OnError = "fail"
+	// Skip logs the error and continues with the next frame
+	Skip OnError = "skip"
+	// Retry runs the frame again and fails once all retries are used
+	Retry OnError = "retry"
+	// Dead sends the frame and its error to a dead-letter pipe and continues with the next frame
+	Dead OnError = "dead"
+)
+
+// Policy describes how a pipe handles errors
+type Policy struct {
+	OnError OnError
+	// Retries is the number of times a failed frame is retried before OnError applies
+	Retries int
+	// Backoff is how long to wait before the first retry, doubling after every attempt
+	Backoff time.Duration
+	// DeadLetter creates the pipe that each failed frame is sent to when OnError is Dead
+	DeadLetter Constructor
+}
+
+// reuses returns true if a frame may be read again after it fails
+func (policy Policy) reuses() bool {
+	return policy.Retries > 0 || policy.OnError == Dead
+}
+
+// constructor wraps create so that each pipe it creates uses this policy,
+// each with its own dead-letter pipe
+func (policy Policy) constructor(create Constructor) Constructor {
+	return func() (Pipe, error) {
+		p, err := create()
+		if err != nil {
+			return nil, err
+		}
+		var pp = &PolicyPipe{
+			Pipe:   p,
+			Policy: policy,
+		}
+		if policy.OnError == Dead && policy.DeadLetter != nil {
+			pp.DeadLetter, err = policy.DeadLetter()
+			if err != nil {
+				return nil, errors.Wrap(err, "dead-letter")
+			}
+		}
+		return pp, nil
+	}
+}
+
+// discardStream discards all writes
+type discardStream struct{}
+
+func (discardStream) Read(cancel <-chan struct{}) (*DataFrame, error) {
+	return nil, io.EOF
+}
+
+func (discardStream) Write(cancel <-chan struct{}, obj interface{}) error {
+	return nil
+}
+
+func (s discardStream) With(*DataFrame) Stream {
+	return s
+}
+
+// policyStream remembers the last frame read by a pipe so that it can be read again.
+// If the frame is a reader then a copy of it is kept in memory, so that it can still be read after the pipe has read it.
+type policyStream struct {
+	Stream
+	buffer bool        // keep a copy of readers
+	f      *DataFrame  // the last frame read
+	spare  interface{} // an unread copy of the object of f, if it is a reader
+	retry  bool        // read f again on the next read
+}
+
+func (s *policyStream) Read(cancel <-chan struct{}) (*DataFrame, error) {
+	if s.retry {
+		s.retry = false
+		return s.next()
+	}
+	f, err := s.Stream.Read(cancel)
+	s.f, s.spare = f, nil
+	if err != nil {
+		return f, err
+	}
+	if _, ok := f.Object.(io.Reader); ok && s.buffer {
+		s.spare = f.Object
+	}
+	return s.next()
+}
+
+// next returns f with a copy of its object that hasn't been read yet
+func (s *policyStream) next() (*DataFrame, error) {
+	if s.spare == nil {
+		return s.f, nil
+	}
+	copies, err := tap.Duplicate(s.spare, 2)
+	if err != nil {
+		return nil, err
+	}
+	s.spare = copies[1]
+
+	f := *s.f
+	f.Object = copies[0]
+	f.context = nil
+	return &f, nil
+}
+
+// deadLetter is a running dead-letter pipe
+type deadLetter struct {
+	input chan *DataFrame
+	done  chan struct{}
+	err   error
+}
+
+func (d *deadLetter) send(ctx context.Context, f *DataFrame) error {
+	select {
+	case d.input <- f:
+		return nil
+	case <-d.done:
+		if d.err != nil {
+			return errors.Wrap(d.err, "dead-letter")
+		}
+		return errors.New("dead-letter pipe stopped before all failures were sent")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *deadLetter) close() error {
+	close(d.input)
+	<-d.done
+	return d.err
+}
+
+// PolicyPipe runs a pipe, handling any error that occurs while processing a frame using Policy.
+// After an error the pipe is started again, so it must not be used with pipes that keep state across frames (see Pkg.Stateful).
+// Any objects written by the pipe for a failed frame are not withdrawn, so a retried frame may produce them again.
+// Readers are kept in memory while they are processed if they may be retried or sent to the dead-letter.
+type PolicyPipe struct {
+	Pipe   Pipe
+	Policy Policy
+	// DeadLetter is sent each failed frame when the policy is Dead.
+	// The frame has the error message in its stack as error.
+	DeadLetter Pipe
+}
+
+func (p *PolicyPipe) startDeadLetter(ctx context.Context) *deadLetter {
+	d := &deadLetter{
+		input: make(chan *DataFrame),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(d.done)
+		d.err = SubPipe{{Pipe: p.DeadLetter}}.Go(ctx, &branchStream{
+			ctx:   ctx,
+			input: d.input,
+			out:   discardStream{},
+		})
+	}()
+	return d
+}
+
+// wait waits before the given retry attempt
+func (p *PolicyPipe) wait(ctx context.Context, attempt int) error {
+	select {
+	case <-time.After(p.Policy.Backoff << uint(attempt-1)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *PolicyPipe) Go(ctx context.Context, stream Stream) (err error) {
+	if p.Policy.OnError == Dead && p.DeadLetter != nil {
+		d := p.startDeadLetter(ctx)
+		defer func() {
+			// The pipe normally ends with io.EOF, which must not hide an error from the dead-letter
+			if e := d.close(); e != nil && (err == nil || errors.Cause(err) == io.EOF) {
+				err = errors.Wrap(e, "dead-letter")
+			}
+		}()
+		return p.run(ctx, stream, d)
+	}
+	return p.run(ctx, stream, nil)
+}
+
+func (p *PolicyPipe) run(ctx context.Context, stream Stream, dead *deadLetter) error {
+	var (
+		s        = &policyStream{Stream: stream, buffer: p.Policy.reuses()}
+		failed   *DataFrame
+		attempts int
+	)
+	for {
+		err := p.Pipe.Go(ctx, s)
+		switch errors.Cause(err) {
+		case nil, io.EOF, context.Canceled, ErrIOCancelled:
+			return err
+		}
+
+		f := s.f
+		if f != failed {
+			failed, attempts = f, 0
+		}
+
+		if attempts < p.Policy.Retries {
+			attempts++
+			statsOf(ctx).error()
+			logrus.Warnf("%T: retry %d of %d for %s: %v", p.Pipe, attempts, p.Policy.Retries, f, err)
+			if e := p.wait(ctx, attempts); e != nil {
+				return e
+			}
+			s.retry = f != nil
+			continue
+		}
+
+		// Errors that occur outside of processing a frame cannot be skipped
+		if f == nil {
+			return err
+		}
+
+		// Errors that end the pipe are counted by Run
+		switch {
+		case p.Policy.OnError == Skip:
+			statsOf(ctx).error()
+			logrus.Warnf("%T: skipped %s: %v", p.Pipe, f, err)
+		case p.Policy.OnError == Dead && dead != nil:
+			statsOf(ctx).error()
+			logrus.Debugf("%T: sending %s to dead-letter: %v", p.Pipe, f, err)
+			df, e := s.next()
+			if e == nil {
+				e = dead.send(ctx, df.AppendStack(Stack{"error": err.Error()}))
+			}
+			if e != nil {
+				return e
+			}
+		default:
+			return err
+		}
+		s.f, s.spare = nil, nil
+	}
+}
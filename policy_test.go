@@ -0,0 +1,254 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"github.com/relvacode/pipe/console"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failPipe writes each input number, failing for odd numbers until it has failed Fails times.
+// If Fails is negative then it always fails for odd numbers.
+type failPipe struct {
+	Fails int
+	count int
+}
+
+func (p *failPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+		if f.Object.(int)%2 == 1 && (p.Fails < 0 || p.count < p.Fails) {
+			p.count++
+			return errors.New("odd")
+		}
+		err = stream.Write(nil, f.Object)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readFailPipe reads each reader input, failing after reading it until it has failed Fails times,
+// then writes what it read
+type readFailPipe struct {
+	Fails int
+	count int
+}
+
+func (p *readFailPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(f.Object.(io.Reader))
+		if err != nil {
+			return err
+		}
+		if p.Fails < 0 || p.count < p.Fails {
+			p.count++
+			return errors.New("bad input")
+		}
+		err = stream.Write(nil, string(b))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readCollectPipe writes the content of each reader input as a string
+type readCollectPipe struct {
+	collectPipe
+}
+
+func (p *readCollectPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(f.Object.(io.Reader))
+		if err != nil {
+			return err
+		}
+		p.Frames = append(p.Frames, f.Copy(string(b), nil))
+	}
+}
+
+func runPolicyTest(t *testing.T, p Pipe) ([]*DataFrame, error) {
+	var result = new(collectPipe)
+	err := Run(context.Background(), []Runnable{
+		{Pipe: sliceSourcePipe{0, 1, 2, 3}},
+		{Pipe: p},
+		{Pipe: result},
+	}).ErrorOrNil()
+	return result.Frames, err
+}
+
+func TestPolicyPipe(t *testing.T) {
+	t.Run("fail", func(t *testing.T) {
+		_, err := runPolicyTest(t, &PolicyPipe{Pipe: &failPipe{Fails: -1}, Policy: Policy{OnError: Fail}})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+	t.Run("skip", func(t *testing.T) {
+		frames, err := runPolicyTest(t, &PolicyPipe{Pipe: &failPipe{Fails: -1}, Policy: Policy{OnError: Skip}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 2 || frames[0].Object != 0 || frames[1].Object != 2 {
+			t.Fatalf("expected only even numbers but got %v", frames)
+		}
+	})
+	t.Run("retry", func(t *testing.T) {
+		frames, err := runPolicyTest(t, &PolicyPipe{Pipe: &failPipe{Fails: 2}, Policy: Policy{OnError: Retry, Retries: 2, Backoff: time.Millisecond}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 4 {
+			t.Fatalf("expected all 4 numbers but got %v", frames)
+		}
+		for i, f := range frames {
+			if f.Object != i {
+				t.Fatalf("expected frame %d to be %d but got %v", i, i, f.Object)
+			}
+		}
+	})
+	t.Run("retries exhausted", func(t *testing.T) {
+		_, err := runPolicyTest(t, &PolicyPipe{Pipe: &failPipe{Fails: 2}, Policy: Policy{OnError: Retry, Retries: 1, Backoff: time.Millisecond}})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+	t.Run("dead", func(t *testing.T) {
+		var dead = new(collectPipe)
+		frames, err := runPolicyTest(t, &PolicyPipe{Pipe: &failPipe{Fails: -1}, Policy: Policy{OnError: Dead}, DeadLetter: dead})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 2 {
+			t.Fatalf("expected only even numbers but got %v", frames)
+		}
+		if len(dead.Frames) != 2 || dead.Frames[0].Object != 1 || dead.Frames[1].Object != 3 {
+			t.Fatalf("expected odd numbers in the dead-letter but got %v", dead.Frames)
+		}
+		if dead.Frames[0].Stack["error"] != "odd" {
+			t.Fatalf("expected error in the stack of the dead-letter but got %v", dead.Frames[0].Stack["error"])
+		}
+	})
+	t.Run("dead-letter fails", func(t *testing.T) {
+		_, err := runPolicyTest(t, &PolicyPipe{Pipe: &failPipe{Fails: -1}, Policy: Policy{OnError: Dead}, DeadLetter: &failPipe{Fails: -1}})
+		if err == nil || !strings.Contains(err.Error(), "dead-letter") {
+			t.Fatalf("expected an error from the dead-letter but got %v", err)
+		}
+	})
+	t.Run("retry reader", func(t *testing.T) {
+		var result = new(collectPipe)
+		err := Run(context.Background(), []Runnable{
+			{Pipe: sliceSourcePipe{strings.NewReader("a"), strings.NewReader("b")}},
+			{Pipe: &PolicyPipe{Pipe: &readFailPipe{Fails: 1}, Policy: Policy{OnError: Retry, Retries: 1, Backoff: time.Millisecond}}},
+			{Pipe: result},
+		}).ErrorOrNil()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Frames) != 2 || result.Frames[0].Object != "a" || result.Frames[1].Object != "b" {
+			t.Fatalf("expected each reader to be read again after it fails but got %v", result.Frames)
+		}
+	})
+	t.Run("dead-letter reader", func(t *testing.T) {
+		var dead = new(readCollectPipe)
+		err := Run(context.Background(), []Runnable{
+			{Pipe: sliceSourcePipe{strings.NewReader("a")}},
+			{Pipe: &PolicyPipe{Pipe: &readFailPipe{Fails: -1}, Policy: Policy{OnError: Dead, Retries: 1, Backoff: time.Millisecond}, DeadLetter: dead}},
+			{Pipe: new(collectPipe)},
+		}).ErrorOrNil()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dead.Frames) != 1 || dead.Frames[0].Object != "a" {
+			t.Fatalf("expected the dead-letter to read the failed reader but got %v", dead.Frames)
+		}
+	})
+}
+
+func TestPolicyPipe_Stats(t *testing.T) {
+	// Retried and skipped frames are counted by the policy and the error that ends the pipe by Run
+	for _, test := range []struct {
+		Policy Policy
+		Expect uint64
+	}{
+		{Policy{OnError: Fail, Retries: 2, Backoff: time.Millisecond}, 3},
+		{Policy{OnError: Skip}, 2},
+	} {
+		var stats = new(Stats)
+		_ = Run(context.Background(), []Runnable{
+			{Pipe: sliceSourcePipe{0, 1, 2, 3}},
+			{Pipe: &PolicyPipe{Pipe: &failPipe{Fails: -1}, Policy: test.Policy}, Stats: stats},
+			{Pipe: new(collectPipe)},
+		}).ErrorOrNil()
+		if stats.Errors() != test.Expect {
+			t.Fatalf("%s: expected %d errors but got %d", test.Policy.OnError, test.Expect, stats.Errors())
+		}
+	}
+}
+
+func TestModify_DeadLetter(t *testing.T) {
+	reg := NewRegistry(Pkg{
+		Name: "dead",
+		Constructor: func(*console.Command) Pipe {
+			return new(collectPipe)
+		},
+	})
+	rn, err := Parse(strings.NewReader("dead with jobs=2 deadletter=dead"), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := rn[0].Pipe.(*ParallelPipe)
+	if a, b := p.Pipes[0].(*PolicyPipe).DeadLetter, p.Pipes[1].(*PolicyPipe).DeadLetter; a == nil || a == b {
+		t.Fatal("expected each copy of the pipe to have its own dead-letter")
+	}
+
+	_, err = Parse(strings.NewReader("dead with deadletter=missing"), reg)
+	if err == nil || !strings.Contains(err.Error(), "unknown pipe") {
+		t.Fatalf("expected an error for an unknown dead-letter but got %v", err)
+	}
+}
+
+func TestModify_Stateful(t *testing.T) {
+	reg := NewRegistry(Pkg{
+		Name:     "total",
+		Stateful: true,
+		Constructor: func(*console.Command) Pipe {
+			return new(collectPipe)
+		},
+	})
+	alias, err := NewAliasPkg("alias", "total", reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.Define(alias)
+
+	for script, fails := range map[string]bool{
+		"total":                       false,
+		"total with jobs=2":           false,
+		"total with errors=skip":      true,
+		"total with retries=1":        true,
+		"total with deadletter=total": true,
+		"alias with errors=skip":      true,
+		"( total ) with errors=skip":  false,
+	} {
+		_, err := Parse(strings.NewReader(script), reg)
+		if fails != (err != nil) {
+			t.Fatalf("%s: expected an error %t but got %v", script, fails, err)
+		}
+	}
+}
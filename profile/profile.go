@@ -5,20 +5,24 @@ import (
 	"github.com/minio/go-homedir"
 	"github.com/pkg/errors"
 	"github.com/relvacode/pipe"
-	"github.com/relvacode/pipe/console"
+	"github.com/sirupsen/logrus"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 const (
 	// ProfileFile is the file name containing the user's pipe profile in that user's home directory.
 	File = ".pipe_profile"
+	// PluginDir is the directory containing the user's plugins in that user's home directory.
+	PluginDir = ".pipe/plugins"
 )
 
-// Load loads the user's alias profile.
-func Load() error {
+// Load loads the user's alias profile into reg.
+func Load(reg *pipe.Registry) error {
 	f, err := OpenProfile()
 	if err != nil {
 		return err
@@ -30,7 +34,7 @@ func Load() error {
 		return err
 	}
 
-	return RegisterAlias(alias)
+	return RegisterAlias(reg, alias)
 }
 
 func OpenProfile() (io.ReadCloser, error) {
@@ -64,20 +68,70 @@ func GetAlias(r io.Reader) (map[string]string, error) {
 	return alias, nil
 }
 
-func RegisterAlias(alias map[string]string) error {
+// RegisterAlias defines each alias in reg
+func RegisterAlias(reg *pipe.Registry, alias map[string]string) error {
 	for k, cmd := range alias {
-
-		pipes, err := pipe.Parse(strings.NewReader(cmd), pipe.Lib)
+		pkg, err := pipe.NewAliasPkg(k, cmd, reg)
 		if err != nil {
 			return errors.Wrapf(err, "parse alias %q", k)
 		}
+		reg.Define(pkg)
+	}
+	return nil
+}
+
+// LoadPlugins loads each plugin in the user's plugin directory into reg.
+func LoadPlugins(reg *pipe.Registry) error {
+	home, err := homedir.Dir()
+	if err != nil {
+		return err
+	}
+	return RegisterPlugins(reg, filepath.Join(home, PluginDir))
+}
+
+// RegisterPlugins defines each executable file in dir as a plugin in reg.
+// Every plugin is asked to describe itself at the same time, so a slow plugin only delays startup by its own timeout.
+// Plugins that fail to describe themselves are skipped with a warning.
+func RegisterPlugins(reg *pipe.Registry, dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "plugins")
+	}
+
+	var (
+		paths []string
+		wg    sync.WaitGroup
+	)
+	for _, f := range files {
+		if f.IsDir() || f.Mode()&0111 == 0 {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, f.Name()))
+	}
 
-		pipe.Define(pipe.Pkg{
-			Name: k,
-			Constructor: func(console *console.Command) pipe.Pipe {
-				return pipe.SubPipe(pipes)
-			},
-		})
+	var (
+		pkgs = make([]pipe.Pkg, len(paths))
+		errs = make([]error, len(paths))
+	)
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			pkgs[i], errs[i] = pipe.NewPluginPkg(path)
+		}(i, path)
+	}
+	wg.Wait()
+
+	// Plugins are defined in the order of their files so that the same one wins if two have the same name
+	for i, path := range paths {
+		if errs[i] != nil {
+			logrus.Warnf("plugin %s: %v", path, errs[i])
+			continue
+		}
+		reg.Define(pkgs[i])
 	}
 	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/relvacode/pipe/console"
 	"sort"
 	"strings"
+	"sync"
 )
 
 // A InitFn constructs an instance of the module
@@ -18,35 +19,143 @@ func Family(names ...string) string {
 	return strings.Join(names, ".")
 }
 
+// Kind is a kind of object read or written by a pipe
+type Kind string
+
+const (
+	// Any object
+	Any Kind = "any"
+	// Nothing is read or written
+	Nothing Kind = "nothing"
+	// Reader is a file-like object such as a file, request or response body
+	Reader Kind = "reader"
+	// String is text
+	String Kind = "string"
+	// Number is an integer or floating point number
+	Number Kind = "number"
+	// Bool is true or false
+	Bool Kind = "bool"
+	// Time is a point in time
+	Time Kind = "time"
+	// Map is an object with named values such as a decoded JSON object
+	Map Kind = "map"
+	// List is a list of objects such as a decoded JSON array
+	List Kind = "list"
+	// Same is used as the output of a pipe that writes the same kinds of object that it reads
+	Same Kind = "same as input"
+)
+
+// An Example is an example use of a pipe
+type Example struct {
+	// Description describes what the example does
+	Description string
+	// Script is the pipe script of the example
+	Script string
+}
+
 // A Pkg describes a package - a pipe and/or a family of pipes.
 type Pkg struct {
 	// Name is the one-word name of this pipe or package.
 	Name string
 	// Constructor is a function to build an instance of this pipe.
 	Constructor InitFn
+	// Description is a short sentence describing what this pipe does.
+	Description string
+	// Input are the kinds of object this pipe reads. If empty the pipe reads any object.
+	Input []Kind
+	// Output are the kinds of object this pipe writes. If empty the pipe may write any object.
+	Output []Kind
+	// Examples are worked examples of using this pipe.
+	Examples []Example
+	// Alias, if not empty, is the pipe script that this pipe runs in its place.
+	Alias string
+	// Plugin, if not empty, is the path of the plugin program that runs this pipe.
+	Plugin string
+	// Stateful is true if the pipe keeps state across frames, such as a running total.
+	// Error policies restart a pipe after it fails so they can't be used with stateful pipes.
+	Stateful bool
+}
+
+// A Registry is the set of pipes available to a script by name.
+// A registry can be layered on top of another, where pipes defined in the layer hide those of the same name below it
+// without changing the registry below.
+// Registries are safe to use concurrently.
+type Registry struct {
+	parent *Registry
+
+	mtx  sync.RWMutex
+	pkgs map[string]Pkg
+}
+
+// NewRegistry creates a registry containing pkgs
+func NewRegistry(pkgs ...Pkg) *Registry {
+	var r = &Registry{
+		pkgs: make(map[string]Pkg, len(pkgs)),
+	}
+	for _, pkg := range pkgs {
+		r.pkgs[pkg.Name] = pkg
+	}
+	return r
+}
+
+// Layer creates a registry on top of this one containing pkgs.
+// Pipes defined in the new registry are not visible to this one.
+func (r *Registry) Layer(pkgs ...Pkg) *Registry {
+	l := NewRegistry(pkgs...)
+	l.parent = r
+	return l
+}
+
+// Define adds pkg to this registry, replacing any pipe with the same name
+func (r *Registry) Define(pkg Pkg) {
+	r.mtx.Lock()
+	r.pkgs[pkg.Name] = pkg
+	r.mtx.Unlock()
 }
 
-type registry map[string]Pkg
+// Lookup returns the pipe with the given name from the highest layer that defines it
+func (r *Registry) Lookup(name string) (Pkg, bool) {
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		pkg, ok := r.pkgs[name]
+		r.mtx.RUnlock()
+		if ok {
+			return pkg, true
+		}
+	}
+	return Pkg{}, false
+}
+
+// Sorted returns a sorted list of all pipes visible from this registry
+func (r *Registry) Sorted() []Pkg {
+	var all = make(map[string]Pkg)
+	for l := r; l != nil; l = l.parent {
+		l.mtx.RLock()
+		for k, pkg := range l.pkgs {
+			if _, ok := all[k]; !ok {
+				all[k] = pkg
+			}
+		}
+		l.mtx.RUnlock()
+	}
 
-// Sorted returns a sorted list of all install packages
-func (r registry) Sorted() []Pkg {
-	var keys = make([]string, len(r))
-	var i int
-	for k := range r {
-		keys[i] = k
-		i++
+	var keys = make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 	var sorted = make([]Pkg, len(keys))
 	for i, k := range keys {
-		sorted[i] = r[k]
+		sorted[i] = all[k]
 	}
 	return sorted
 }
 
-var Lib = make(registry)
+// Lib is the registry of all native pipes.
+// Programs should layer their own pipes and aliases on top of it rather than defining them in Lib.
+var Lib = NewRegistry()
 
 // Define registers the given package with the global library
 func Define(pkg Pkg) {
-	Lib[pkg.Name] = pkg
+	Lib.Define(pkg)
 }
@@ -0,0 +1,77 @@
+package pipe
+
+import (
+	"fmt"
+	"github.com/relvacode/pipe/console"
+	"sync"
+	"testing"
+)
+
+func testPkg(name, description string) Pkg {
+	return Pkg{
+		Name:        name,
+		Description: description,
+		Constructor: func(*console.Command) Pipe {
+			return TestPipe{}
+		},
+	}
+}
+
+func TestRegistry_Layer(t *testing.T) {
+	var (
+		base  = NewRegistry(testPkg("a", "base"), testPkg("b", "base"))
+		layer = base.Layer(testPkg("b", "layer"))
+	)
+	layer.Define(testPkg("c", "layer"))
+
+	for name, expect := range map[string]string{"a": "base", "b": "layer", "c": "layer"} {
+		pkg, ok := layer.Lookup(name)
+		if !ok || pkg.Description != expect {
+			t.Fatalf("expected %s from the %s registry but got %v", name, expect, pkg.Description)
+		}
+	}
+	if pkg, _ := base.Lookup("b"); pkg.Description != "base" {
+		t.Fatalf("expected the base registry to be unchanged but got b from %s", pkg.Description)
+	}
+	if _, ok := base.Lookup("c"); ok {
+		t.Fatal("expected c to only be defined in the layer")
+	}
+
+	var names []string
+	for _, pkg := range layer.Sorted() {
+		names = append(names, pkg.Name+" "+pkg.Description)
+	}
+	if s := fmt.Sprint(names); s != "[a base b layer c layer]" {
+		t.Fatalf("unexpected sorted registry %s", s)
+	}
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	var (
+		base  = NewRegistry()
+		layer = base.Layer()
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				base.Define(testPkg(fmt.Sprintf("base-%d-%d", i, j), ""))
+				layer.Define(testPkg(fmt.Sprintf("layer-%d-%d", i, j), ""))
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				layer.Lookup(fmt.Sprintf("base-%d-%d", i, j))
+				layer.Sorted()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := len(layer.Sorted()); n != 800 {
+		t.Fatalf("expected 800 pipes but got %d", n)
+	}
+}
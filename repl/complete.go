@@ -0,0 +1,105 @@
+package repl
+
+import (
+	"github.com/relvacode/pipe"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// commands are the commands of the REPL that are not pipe scripts
+var commands = []string{":help", ":quit", ":script", ":undo"}
+
+// isName returns true if r can be part of the name of a pipe
+func isName(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-", r)
+}
+
+// isKey returns true if r can be part of a key of the stack
+func isKey(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// wordStart returns the offset of the word at the end of line made of runes matching fn
+func wordStart(line string, fn func(rune) bool) int {
+	var runes = []rune(line)
+	i := len(runes)
+	for i > 0 && fn(runes[i-1]) {
+		i--
+	}
+	return len(string(runes[:i]))
+}
+
+// matching returns all of the candidates starting with prefix
+func matching(prefix string, candidates []string) []string {
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
+
+// complete returns the offset of the word being written at the end of line and all of the ways to complete it.
+// The first word of each pipe is completed from names,
+// other words and any word inside a {{ template }} are completed from keys.
+func complete(line string, names, keys []string) (int, []string) {
+	if strings.HasPrefix(line, ":") && !strings.ContainsRune(line, ' ') {
+		return 0, matching(line, commands)
+	}
+
+	if open := strings.LastIndex(line, "{{"); open > strings.LastIndex(line, "}}") {
+		start := wordStart(line, isKey)
+		return start, matching(line[start:], keys)
+	}
+
+	var (
+		start   = wordStart(line, isName)
+		segment = line[:start]
+	)
+	for _, sep := range []string{"::", "("} {
+		if i := strings.LastIndex(segment, sep); i > -1 {
+			segment = segment[i+len(sep):]
+		}
+	}
+	if strings.TrimSpace(segment) == "" {
+		return start, matching(line[start:], names)
+	}
+
+	start = wordStart(line, isKey)
+	if start == len(line) {
+		return start, nil
+	}
+	return start, matching(line[start:], keys)
+}
+
+// prefix returns the longest prefix shared by all candidates
+func prefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	p := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, p) {
+			p = p[:len(p)-1]
+		}
+	}
+	return p
+}
+
+// libraryNames returns the names of all pipes in reg and the branching pipes
+func libraryNames(reg *pipe.Registry) []string {
+	var n = []string{"merge", "tee"}
+	for _, pkg := range reg.Sorted() {
+		n = append(n, pkg.Name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// Complete returns the offset of the word being written at the end of line and all of the ways to complete it,
+// using the names of pipes in the registry of the session and the keys of the frames kept from the last stage.
+func (s *Session) Complete(line string) (int, []string) {
+	return complete(line, libraryNames(s.Registry), s.Keys())
+}
@@ -0,0 +1,44 @@
+package repl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	var (
+		names = []string{"json", "jq", "print", "split", "url.get", "url.post"}
+		keys  = []string{"_index", "f", "file", "this"}
+	)
+	cases := []struct {
+		Line   string
+		Start  int
+		Expect []string
+	}{
+		{Line: "j", Start: 0, Expect: []string{"json", "jq"}},
+		{Line: "open *.json :: ur", Start: 15, Expect: []string{"url.get", "url.post"}},
+		{Line: "json ::url.p", Start: 7, Expect: []string{"url.post"}},
+		{Line: "tee ( sp", Start: 6, Expect: []string{"split"}},
+		{Line: "print {{f", Start: 8, Expect: []string{"f", "file"}},
+		{Line: "print {{ this }} {{ _", Start: 20, Expect: []string{"_index"}},
+		{Line: "select th", Start: 7, Expect: []string{"this"}},
+		{Line: "split ", Start: 6},
+		{Line: "print {{f}} x", Start: 12},
+		{Line: ":u", Start: 0, Expect: []string{":undo"}},
+	}
+	for _, tc := range cases {
+		start, got := complete(tc.Line, names, keys)
+		if start != tc.Start || !reflect.DeepEqual(got, tc.Expect) {
+			t.Fatalf("%q: wanted %v at %d but got %v at %d", tc.Line, tc.Expect, tc.Start, got, start)
+		}
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	if p := prefix([]string{"url.get", "url.post", "url.put"}); p != "url." {
+		t.Fatalf("expected url. but got %q", p)
+	}
+	if p := prefix([]string{"print"}); p != "print" {
+		t.Fatalf("expected print but got %q", p)
+	}
+}
@@ -0,0 +1,193 @@
+// Package repl builds pipelines interactively, one stage at a time.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/relvacode/pipe"
+	"github.com/relvacode/pipe/dsl"
+	"github.com/relvacode/pipe/tap"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+)
+
+const help = `Each line is added as a new stage of the pipeline, using the frames kept from the stage before it.
+Press tab to complete the name of a pipe or a name available to templates and expressions.
+
+    :undo     remove the last stage
+    :script   print the pipeline built so far
+    :help     print this help
+    :quit     quit, also Ctrl-D
+
+Press Ctrl-C to stop a stage that is taking too long.
+`
+
+// reader reads a line of input after writing prompt
+type reader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// display renders err with the line of the script that caused it, if known
+func display(err error) string {
+	if e, ok := dsl.AsError(err); ok {
+		return e.Display()
+	}
+	return strings.TrimRight(err.Error(), "\n")
+}
+
+// Run runs the session interactively, reading each line from in and writing the frames of each stage to out.
+// Scripts are added as stages before reading from in.
+// If in is a terminal then tab completes the word being written.
+// Run returns once the input ends or :quit is entered.
+func Run(s *Session, in *os.File, out io.Writer, scripts ...string) error {
+	var r reader = &lineReader{scanner: bufio.NewScanner(in)}
+	// Lines are read without editing or completion if raw mode isn't available, such as on some Windows consoles
+	if restore, err := rawTerminal(in); err == nil {
+		restore()
+		r = &terminal{
+			f:        in,
+			in:       bufio.NewReader(in),
+			out:      out,
+			complete: s.Complete,
+		}
+	}
+
+	var signals = make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	for _, script := range scripts {
+		add(s, out, script, signals)
+	}
+
+	fmt.Fprintln(out, "Add a pipe to see what it writes, use :help for help")
+	for {
+		line, err := r.ReadLine("pipe> ")
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case line == ":quit", line == ":q":
+			return nil
+		case line == ":help":
+			fmt.Fprint(out, help)
+		case line == ":script":
+			fmt.Fprintf(out, "pipe '%s'\n", s.Script())
+		case line == ":undo":
+			if !s.Undo() {
+				fmt.Fprintln(out, "nothing to undo")
+				continue
+			}
+			preview(out, s.Frames())
+		case strings.HasPrefix(line, ":"):
+			fmt.Fprintf(out, "unknown command %s, use :help for help\n", line)
+		default:
+			add(s, out, line, signals)
+		}
+	}
+}
+
+// add adds script as a stage of the session and previews its frames.
+// The stage is cancelled if an interrupt is received.
+func add(s *Session, out io.Writer, script string, signals <-chan os.Signal) {
+	// Forget any interrupt received while waiting for input
+	select {
+	case <-signals:
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	stage, err := s.Add(ctx, script)
+	if err != nil {
+		fmt.Fprintln(out, display(err))
+		return
+	}
+	for _, w := range stage.Warnings {
+		fmt.Fprintln(out, display(w))
+	}
+
+	preview(out, stage.Frames)
+	switch {
+	case stage.TimedOut:
+		fmt.Fprintf(out, "stopped after %s\n", s.Timeout)
+	case stage.Limited:
+		fmt.Fprintf(out, "stopped after %d frames\n", s.Sample)
+	}
+}
+
+// preview writes the index, type, tag and stack of each frame along with the start of its object
+func preview(out io.Writer, frames []*pipe.DataFrame) {
+	if len(frames) == 0 {
+		fmt.Fprintln(out, "no frames written")
+	}
+	for _, f := range frames {
+		fmt.Fprintf(out, "#%d %T", f.Index, f.Object)
+		if f.Tag != nil {
+			fmt.Fprintf(out, " as %s", f.Tag)
+		}
+		if len(f.Stack) > 0 {
+			var keys = make([]string, 0, len(f.Stack))
+			for k := range f.Stack {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			fmt.Fprintf(out, " with %s", strings.Join(keys, ", "))
+		}
+		fmt.Fprintf(out, "\n    %s\n", summary(f))
+	}
+}
+
+// summaryLength is the number of characters of an object shown in a preview
+const summaryLength = 72
+
+// summary returns the start of the first line of the object of f, reading a copy of it if it is a reader
+func summary(f *pipe.DataFrame) string {
+	x, err := replay(f)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+
+	var s string
+	if r, ok := x.(io.Reader); ok {
+		var b = make([]byte, summaryLength*4)
+		n, err := io.ReadFull(r, b)
+		_ = tap.Close(x)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Sprintf("<%v>", err)
+		}
+		s = string(b[:n])
+	} else {
+		s = fmt.Sprint(x)
+	}
+
+	var (
+		lines = strings.SplitN(s, "\n", 2)
+		runes = []rune(lines[0])
+	)
+	if len(runes) > summaryLength {
+		return string(runes[:summaryLength]) + "..."
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		return string(runes) + " ..."
+	}
+	return string(runes)
+}
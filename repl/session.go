@@ -0,0 +1,216 @@
+package repl
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/relvacode/pipe"
+	"github.com/relvacode/pipe/tap"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// A Stage is one or more pipes added to a session and a sample of the frames they wrote
+type Stage struct {
+	// Script is the pipe script of this stage
+	Script string
+	// Frames are the first frames written by this stage.
+	// Readers are kept in memory, or re-opened in the case of files, so that they can be read again.
+	Frames []*pipe.DataFrame
+	// Limited is true if the stage was stopped after writing Sample frames
+	Limited bool
+	// TimedOut is true if the stage was stopped because it took longer than the Timeout of its session
+	TimedOut bool
+	// Warnings are the problems found checking the pipes of this stage
+	Warnings []error
+}
+
+// Session builds a pipeline one stage at a time.
+// Each stage is run using the frames kept from the stage before it.
+type Session struct {
+	// Sample is the number of frames kept from each stage
+	Sample int
+	// Timeout is how long to wait for a stage to write its sample
+	Timeout time.Duration
+	// Registry is where the pipes of each stage are found
+	Registry *pipe.Registry
+
+	stages []*Stage
+}
+
+// NewSession creates a session with no stages,
+// where the first stage is given a single empty frame.
+func NewSession() *Session {
+	return &Session{
+		Sample:   10,
+		Timeout:  10 * time.Second,
+		Registry: pipe.Lib,
+	}
+}
+
+// Stages returns all of the stages of this session
+func (s *Session) Stages() []*Stage {
+	return s.stages
+}
+
+// Frames returns the frames kept from the last stage
+func (s *Session) Frames() []*pipe.DataFrame {
+	if len(s.stages) == 0 {
+		return []*pipe.DataFrame{pipe.NewDataFrame(nil, nil)}
+	}
+	return s.stages[len(s.stages)-1].Frames
+}
+
+// Script returns the pipe script of all stages of this session
+func (s *Session) Script() string {
+	var scripts = make([]string, len(s.stages))
+	for i, st := range s.stages {
+		scripts[i] = st.Script
+	}
+	return strings.Join(scripts, " :: ")
+}
+
+// Undo removes the last stage, returning false if there are no stages
+func (s *Session) Undo() bool {
+	if len(s.stages) == 0 {
+		return false
+	}
+	s.stages = s.stages[:len(s.stages)-1]
+	return true
+}
+
+// Keys returns the names that templates and expressions can use in the next stage
+func (s *Session) Keys() []string {
+	var (
+		seen = make(map[string]bool)
+		keys []string
+	)
+	for _, f := range s.Frames() {
+		for k := range f.Context() {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Add parses a pipe script and runs it as a new stage using the frames kept from the last stage.
+// The stage is stopped once it has written Sample frames or after Timeout.
+// If the stage fails, or ctx is cancelled before it completes, then it isn't added.
+func (s *Session) Add(ctx context.Context, script string) (*Stage, error) {
+	modules, err := pipe.Parse(strings.NewReader(script), s.Registry)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		stage = &Stage{
+			Script:   strings.TrimSpace(script),
+			Warnings: pipe.Check(modules),
+		}
+		input    = &replayPipe{frames: s.Frames()}
+		output   = &samplePipe{limit: s.Sample}
+		timedOut int32
+	)
+
+	run, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := time.AfterFunc(s.Timeout, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		cancel()
+	})
+	defer timer.Stop()
+
+	err = pipe.RunIO(run, input, modules, output).ErrorOrNil()
+	if ctx.Err() != nil {
+		return nil, errors.Wrap(ctx.Err(), "interrupted")
+	}
+	stage.TimedOut = atomic.LoadInt32(&timedOut) == 1
+	// Pipes stopped by the timeout may fail because of it
+	if err != nil && !stage.TimedOut {
+		return nil, err
+	}
+
+	stage.Frames = output.frames
+	stage.Limited = output.limited
+	s.stages = append(s.stages, stage)
+	return stage, nil
+}
+
+// replay returns a copy of the object of f that can be used independently of f
+func replay(f *pipe.DataFrame) (interface{}, error) {
+	copies, err := tap.Duplicate(f.Object, 2)
+	if err != nil {
+		return nil, err
+	}
+	f.Object = copies[0]
+	return copies[1], nil
+}
+
+// replayPipe writes a copy of each of its frames
+type replayPipe struct {
+	frames []*pipe.DataFrame
+}
+
+func (p *replayPipe) Go(ctx context.Context, stream pipe.Stream) error {
+	for _, f := range p.frames {
+		x, err := replay(f)
+		if err != nil {
+			return err
+		}
+
+		err = stream.With(&pipe.DataFrame{
+			Tag:    f.Tag,
+			Object: x,
+			Stack:  f.Stack,
+		}).Write(nil, x)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// samplePipe keeps the first frames it reads
+type samplePipe struct {
+	limit   int
+	frames  []*pipe.DataFrame
+	limited bool
+}
+
+func (p *samplePipe) Go(ctx context.Context, stream pipe.Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(p.frames) >= p.limit {
+			_ = tap.Close(f.Object)
+			p.limited = true
+			return nil
+		}
+
+		copies, err := tap.Duplicate(f.Object, 1)
+		if err != nil {
+			return err
+		}
+		if _, ok := f.Object.(tap.Duplicator); ok {
+			_ = tap.Close(f.Object)
+		}
+
+		p.frames = append(p.frames, &pipe.DataFrame{
+			Tag:    f.Tag,
+			Object: copies[0],
+			Index:  f.Index,
+			Stack:  f.Stack,
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	_ "github.com/relvacode/pipe/pipes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// objects returns the object of each frame kept from the last stage
+func objects(t *testing.T, s *Session) []interface{} {
+	var x []interface{}
+	for _, f := range s.Frames() {
+		o, err := replay(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, ok := o.(*bytes.Reader); ok {
+			c, _ := ioutil.ReadAll(b)
+			o = string(c)
+		}
+		x = append(x, o)
+	}
+	return x
+}
+
+func TestSession(t *testing.T) {
+	var s = NewSession()
+	s.Sample = 2
+
+	stage, err := s.Add(context.Background(), "print a,b,c as text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stage.Limited || len(stage.Frames) != 1 {
+		t.Fatalf("expected 1 frame but got %d", len(stage.Frames))
+	}
+
+	stage, err = s.Add(context.Background(), "split , as letter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stage.Limited {
+		t.Fatal("expected the stage to be limited to the sample")
+	}
+	if got := objects(t, s); !reflect.DeepEqual(got, []interface{}{"a", "b"}) {
+		t.Fatalf("unexpected sample %v", got)
+	}
+	if keys := s.Keys(); !reflect.DeepEqual(keys, []string{"_index", "letter", "text", "this"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	// Each stage can be replayed from the sample of the stage before it
+	for i := 0; i < 2; i++ {
+		_, err = s.Add(context.Background(), "print {{letter}}{{letter}}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := objects(t, s); !reflect.DeepEqual(got, []interface{}{"aa", "bb"}) {
+			t.Fatalf("unexpected sample %v", got)
+		}
+		if !s.Undo() {
+			t.Fatal("expected to undo the last stage")
+		}
+	}
+
+	if _, err = s.Add(context.Background(), "print {{nope"); err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if script := s.Script(); script != "print a,b,c as text :: split , as letter" {
+		t.Fatalf("unexpected script %q", script)
+	}
+}
+
+func TestSession_Timeout(t *testing.T) {
+	var s = NewSession()
+	s.Sample = 1000
+	s.Timeout = 100 * time.Millisecond
+
+	stage, err := s.Add(context.Background(), "every 10ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stage.TimedOut || len(stage.Frames) == 0 {
+		t.Fatalf("expected frames before timing out but got %d", len(stage.Frames))
+	}
+}
+
+func TestSession_Reader(t *testing.T) {
+	var s = NewSession()
+	for _, script := range []string{"print hello", "buffer"} {
+		if _, err := s.Add(context.Background(), script); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out = new(strings.Builder)
+	preview(out, s.Frames())
+	if out.String() != "#0 *bytes.Reader\n    hello\n" {
+		t.Fatalf("expected a preview of the reader but got\n%s", out)
+	}
+	if got := objects(t, s); !reflect.DeepEqual(got, []interface{}{"hello"}) {
+		t.Fatalf("expected the reader to be read again but got %v", got)
+	}
+}
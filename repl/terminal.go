@@ -0,0 +1,196 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Completer returns the offset of the word being written at the end of line and all of the ways to complete it
+type Completer func(line string) (int, []string)
+
+// terminal reads lines from a terminal, completing words on tab and recalling previous lines with the up and down arrows.
+// The terminal is only in raw mode while a line is read, so that the output of a stage and Ctrl-C behave as normal.
+// Only the end of a line can be edited.
+type terminal struct {
+	f        *os.File
+	in       *bufio.Reader
+	out      io.Writer
+	complete Completer
+	history  []string
+}
+
+// rawTerminal switches f into reading a character at a time without echo,
+// returning a function to restore f to its original state.
+// It returns an error if f isn't a terminal or raw mode isn't supported by it.
+func rawTerminal(f *os.File) (restore func(), err error) {
+	fd := int(f.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("not a terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		_ = term.Restore(fd, state)
+	}, nil
+}
+
+// exit stops the program as if sig was not handled
+func exit(sig os.Signal) {
+	signal.Reset(sig)
+	if p, err := os.FindProcess(os.Getpid()); err == nil && p.Signal(sig) == nil {
+		// Give the signal time to arrive
+		time.Sleep(time.Second)
+	}
+	os.Exit(1)
+}
+
+// redraw writes the prompt and line again over the current line
+func (t *terminal) redraw(prompt string, line []rune) {
+	fmt.Fprintf(t.out, "\r\033[K%s%s", prompt, string(line))
+}
+
+// tab completes the word at the end of line.
+// If there is more than one way to complete the word then each one is printed.
+func (t *terminal) tab(prompt string, line []rune) []rune {
+	if t.complete == nil {
+		return line
+	}
+	s := string(line)
+	start, candidates := t.complete(s)
+	word := s[start:]
+	if len(candidates) == 0 || len(candidates) == 1 && candidates[0] == word {
+		return line
+	}
+
+	if p := prefix(candidates); len(p) > len(word) {
+		s = s[:start] + p
+		t.redraw(prompt, []rune(s))
+		return []rune(s)
+	}
+
+	fmt.Fprintf(t.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	t.redraw(prompt, line)
+	return line
+}
+
+// ReadLine reads one line, returning io.EOF if the input ends or Ctrl-D is pressed on an empty line.
+// Ctrl-C discards the line being written.
+func (t *terminal) ReadLine(prompt string) (string, error) {
+	restore, err := rawTerminal(t.f)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	// Ctrl-C doesn't interrupt in raw mode, but a signal from elsewhere still stops the program,
+	// so restore the terminal before it does
+	var (
+		signals = make(chan os.Signal, 1)
+		done    = make(chan struct{})
+	)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer func() {
+		signal.Stop(signals)
+		close(done)
+	}()
+	go func() {
+		select {
+		case sig := <-signals:
+			restore()
+			exit(sig)
+		case <-done:
+		}
+	}()
+
+	var (
+		line    []rune
+		history = len(t.history)
+	)
+	fmt.Fprint(t.out, prompt)
+	for {
+		r, _, err := t.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(t.out, "\r\n")
+			s := string(line)
+			if strings.TrimSpace(s) != "" {
+				t.history = append(t.history, s)
+			}
+			return s, nil
+		case 3: // Ctrl-C
+			fmt.Fprint(t.out, "^C\r\n")
+			line, history = line[:0], len(t.history)
+			fmt.Fprint(t.out, prompt)
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(t.out, "\r\n")
+				return "", io.EOF
+			}
+		case 21: // Ctrl-U
+			line = line[:0]
+			t.redraw(prompt, line)
+		case 127, '\b':
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				t.redraw(prompt, line)
+			}
+		case '\t':
+			line = t.tab(prompt, line)
+		case 27: // an escape sequence such as an arrow key
+			if b, _ := t.in.ReadByte(); b != '[' {
+				continue
+			}
+			switch b, _ := t.in.ReadByte(); b {
+			case 'A':
+				if history > 0 {
+					history--
+					line = []rune(t.history[history])
+				}
+			case 'B':
+				switch {
+				case history < len(t.history)-1:
+					history++
+					line = []rune(t.history[history])
+				case history == len(t.history)-1:
+					history++
+					line = line[:0]
+				}
+			}
+			t.redraw(prompt, line)
+		default:
+			if r >= ' ' {
+				line = append(line, r)
+				fmt.Fprint(t.out, string(r))
+			}
+		}
+	}
+}
+
+// lineReader reads lines from input that isn't a terminal
+type lineReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *lineReader) ReadLine(string) (string, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/pkg/errors"
+	"github.com/relvacode/pipe/dsl"
 	"github.com/sirupsen/logrus"
 	"io"
 	"strings"
@@ -29,6 +30,37 @@ func (e RuntimeError) ErrorOrNil() error {
 type Runnable struct {
 	Pipe Pipe
 	Tag  *Tag
+
+	// Buffer is the number of frames that can be written to this pipe before the writer blocks.
+	// If zero then DefaultBuffer is used, if negative then the pipe is unbuffered.
+	Buffer int
+	// Stats, if not nil, counts the frames read and written by this pipe
+	Stats *Stats
+
+	// Input and Output are the kinds of object this pipe reads and writes.
+	// If empty then the pipe may read or write any object.
+	Input, Output []Kind
+
+	// source is the command this pipe was parsed from, if any
+	source *dsl.Command
+}
+
+// stream creates the stream for this pipe
+func (r Runnable) stream(ctx context.Context) *stream {
+	var (
+		buffer = r.Buffer
+		stats  = r.Stats
+	)
+	switch {
+	case buffer == 0:
+		buffer = DefaultBuffer
+	case buffer < 0:
+		buffer = 0
+	}
+	if stats == nil {
+		stats = new(Stats)
+	}
+	return newStream(ctx, r.Tag, buffer, stats)
 }
 
 func RunIO(ctx context.Context, input Pipe, modules []Runnable, output Pipe) RuntimeError {
@@ -50,10 +82,10 @@ func Run(ctx context.Context, runnables []Runnable) RuntimeError {
 	defer cancel()
 
 	var streams = make([]*stream, len(runnables))
-	streams[len(streams)-1] = NewStream(ctx, nil)
+	streams[len(streams)-1] = runnables[len(runnables)-1].stream(ctx)
 
 	for i := len(runnables) - 2; i > -1; i-- {
-		var s = NewStream(ctx, runnables[i].Tag)
+		var s = runnables[i].stream(ctx)
 		s.Down(streams[i+1])
 		streams[i] = s
 	}
@@ -74,7 +106,15 @@ func Run(ctx context.Context, runnables []Runnable) RuntimeError {
 			defer s.Close()
 
 			logrus.Debugf("pipe %T started on stream %s", e.Pipe, s)
-			err := e.Pipe.Go(s.ctx, s)
+			s.stats.setState(Running)
+			err := e.Pipe.Go(withStats(s.ctx, s.stats), s)
+			switch errors.Cause(err) {
+			case nil, io.EOF, context.Canceled:
+				s.stats.setState(Done)
+			default:
+				s.stats.error()
+				s.stats.setState(Failed)
+			}
 			if err != nil {
 				err = errors.Wrapf(err, "%T on %s", e.Pipe, s)
 			}
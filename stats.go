@@ -0,0 +1,129 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// State is what a running pipe is currently doing
+type State int32
+
+const (
+	// Waiting pipes have not started yet
+	Waiting State = iota
+	// Running pipes are processing
+	Running
+	// ReadBlocked pipes are waiting for a frame to read
+	ReadBlocked
+	// WriteBlocked pipes are waiting for the next pipe to accept a write
+	WriteBlocked
+	// Done pipes have stopped
+	Done
+	// Failed pipes have stopped with an error
+	Failed
+)
+
+// States are all possible states of a pipe
+var States = []State{Waiting, Running, ReadBlocked, WriteBlocked, Done, Failed}
+
+func (s State) String() string {
+	switch s {
+	case Waiting:
+		return "waiting"
+	case Running:
+		return "running"
+	case ReadBlocked:
+		return "read-blocked"
+	case WriteBlocked:
+		return "write-blocked"
+	case Done:
+		return "done"
+	case Failed:
+		return "failed"
+	}
+	return fmt.Sprintf("State(%d)", int32(s))
+}
+
+// Stats counts the frames that pass through a stream and how long the stream spent waiting.
+// It is safe to read while the stream is running.
+type Stats struct {
+	read         uint64
+	written      uint64
+	errors       uint64
+	readBlocked  int64
+	writeBlocked int64
+	state        int32
+}
+
+// Read is the number of frames read from the stream
+func (s *Stats) Read() uint64 {
+	return atomic.LoadUint64(&s.read)
+}
+
+// Written is the number of objects written to the stream
+func (s *Stats) Written() uint64 {
+	return atomic.LoadUint64(&s.written)
+}
+
+// Errors is the number of errors returned by the pipe, including those handled by an error policy
+func (s *Stats) Errors() uint64 {
+	return atomic.LoadUint64(&s.errors)
+}
+
+// ReadBlocked is the total time spent waiting for a frame to read
+func (s *Stats) ReadBlocked() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.readBlocked))
+}
+
+// WriteBlocked is the total time spent waiting for the next pipe to accept a write
+func (s *Stats) WriteBlocked() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.writeBlocked))
+}
+
+// State is what the pipe is currently doing
+func (s *Stats) State() State {
+	return State(atomic.LoadInt32(&s.state))
+}
+
+func (s *Stats) setState(state State) {
+	atomic.StoreInt32(&s.state, int32(state))
+}
+
+func (s *Stats) error() {
+	atomic.AddUint64(&s.errors, 1)
+}
+
+// block marks the pipe as blocked, returning the time it started waiting
+func (s *Stats) block(state State) time.Time {
+	s.setState(state)
+	return time.Now()
+}
+
+// unblock marks the pipe as running again, adding the time spent waiting since start to total
+func (s *Stats) unblock(start time.Time, total *int64) {
+	atomic.AddInt64(total, int64(time.Since(start)))
+	s.setState(Running)
+}
+
+func (s *Stats) String() string {
+	return fmt.Sprintf("read:%d write:%d errors:%d read-blocked:%s write-blocked:%s", s.Read(), s.Written(), s.Errors(), s.ReadBlocked(), s.WriteBlocked())
+}
+
+type statsKey struct{}
+
+// withStats returns a context that carries the stats of the pipe it is given to
+func withStats(ctx context.Context, stats *Stats) context.Context {
+	return context.WithValue(ctx, statsKey{}, stats)
+}
+
+// statsOf returns the stats of the pipe running with this context.
+// If there are none then the returned stats are not used anywhere else.
+func statsOf(ctx context.Context) *Stats {
+	stats, ok := ctx.Value(statsKey{}).(*Stats)
+	if !ok {
+		return new(Stats)
+	}
+	return stats
+}
@@ -1,6 +1,7 @@
 package tap
 
 import (
+	"context"
 	"github.com/hashicorp/go-multierror"
 	"github.com/sirupsen/logrus"
 	"sync"
@@ -10,32 +11,64 @@ import (
 // This should be used to clean up anything created by pipes in this program.
 type Deferred func() error
 
-type exitHandler struct {
+// A Scope collects deferred functions to be called when whatever created them has finished,
+// such as a single run of a pipeline embedded in another program.
+type Scope struct {
 	mtx sync.Mutex
 	f   []Deferred
 }
 
-var onexit *exitHandler
-
-func init() {
-	onexit = new(exitHandler)
+// NewScope creates a scope without any deferred functions
+func NewScope() *Scope {
+	return new(Scope)
 }
 
-// Defer a function to be called before the program exits
-func Defer(f Deferred) {
-	onexit.mtx.Lock()
-	onexit.f = append(onexit.f, f)
-	onexit.mtx.Unlock()
+// Defer a function to be called when the scope exits
+func (s *Scope) Defer(f Deferred) {
+	s.mtx.Lock()
+	s.f = append(s.f, f)
+	s.mtx.Unlock()
 }
 
-// Exit should be called just before the program exits
-func Exit() (err *multierror.Error) {
-	onexit.mtx.Lock()
-	defer onexit.mtx.Unlock()
+// Exit calls each deferred function of the scope.
+// Each deferred function is only called once, even if Exit is called again.
+func (s *Scope) Exit() (err *multierror.Error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
 
-	for _, f := range onexit.f {
+	for _, f := range s.f {
 		logrus.Debugf("execute deferred function %v", f)
 		err = multierror.Append(err, f())
 	}
+	s.f = nil
 	return err
 }
+
+// ScopeKey is the name of the scope in the context of a template, if it was rendered within one
+const ScopeKey = "_scope"
+
+type scopeKey struct{}
+
+// WithScope returns a context where anything deferred by pipes is deferred to s
+func WithScope(parent context.Context, s *Scope) context.Context {
+	return context.WithValue(parent, scopeKey{}, s)
+}
+
+// ScopeOf returns the scope of ctx, or nil if it doesn't have one
+func ScopeOf(ctx context.Context) *Scope {
+	s, _ := ctx.Value(scopeKey{}).(*Scope)
+	return s
+}
+
+var onexit = NewScope()
+
+// Defer a function to be called before the program exits
+func Defer(f Deferred) {
+	onexit.Defer(f)
+}
+
+// Exit should be called just before the program exits.
+// Each deferred function is only called once, even if Exit is called again.
+func Exit() (err *multierror.Error) {
+	return onexit.Exit()
+}
@@ -11,14 +11,22 @@ func init() {
 	_ = pongo2.RegisterFilter("json", JSONFilter)
 }
 
+// TempFileFilter writes its input to a temporary file that is removed when the program exits
 func TempFileFilter(in *pongo2.Value, _ *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
-	file, err := MkTemp(in.Interface())
-	if err != nil {
-		return nil, &pongo2.Error{
-			OrigError: err,
+	return scopedTempFileFilter(nil)(in, nil)
+}
+
+// scopedTempFileFilter returns a filter that writes its input to a temporary file that is removed when scope exits
+func scopedTempFileFilter(scope *Scope) pongo2.FilterFunction {
+	return func(in *pongo2.Value, _ *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		file, err := MkTemp(in.Interface(), scope)
+		if err != nil {
+			return nil, &pongo2.Error{
+				OrigError: err,
+			}
 		}
+		return pongo2.AsValue(file), nil
 	}
-	return pongo2.AsValue(file), nil
 }
 
 func JSONFilter(in *pongo2.Value, _ *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
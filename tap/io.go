@@ -2,8 +2,10 @@
 package tap
 
 import (
+	"bytes"
 	"github.com/pkg/errors"
 	"io"
+	"io/ioutil"
 	"mime"
 	"os"
 	"path/filepath"
@@ -58,6 +60,38 @@ func ReadProxyCloser(wrapped, original io.Reader) io.ReadCloser {
 	}
 }
 
+// Duplicator is implemented by objects that can create independent copies of themselves.
+type Duplicator interface {
+	// Duplicate returns n copies of this object.
+	Duplicate(n int) ([]interface{}, error)
+}
+
+// Duplicate returns n copies of x that can each be used independently.
+// Readers are read into memory and closed so that each copy can be read separately,
+// unless the reader implements Duplicator.
+// All other values are shared between each copy.
+func Duplicate(x interface{}, n int) ([]interface{}, error) {
+	var copies = make([]interface{}, n)
+	switch v := x.(type) {
+	case Duplicator:
+		return v.Duplicate(n)
+	case io.Reader:
+		b, err := ioutil.ReadAll(v)
+		Close(v)
+		if err != nil {
+			return nil, err
+		}
+		for i := range copies {
+			copies[i] = bytes.NewReader(b)
+		}
+	default:
+		for i := range copies {
+			copies[i] = x
+		}
+	}
+	return copies, nil
+}
+
 func OpenFileInfo(path string, i os.FileInfo) *File {
 	var f = &File{
 		Path:      path,
@@ -93,7 +127,8 @@ type File struct {
 	Extension string
 	Mime      string
 
-	f io.ReadCloser
+	f      io.ReadCloser
+	offset int64 // where reading starts once the file is opened
 }
 
 func (f File) String() string {
@@ -106,14 +141,51 @@ func (f *File) Read(b []byte) (int, error) {
 	}
 	if f.f == nil {
 		var err error
-		f.f, err = os.Open(f.Path)
+		file, err := os.Open(f.Path)
 		if err != nil {
 			return 0, err
 		}
+		if f.offset > 0 {
+			_, err = file.Seek(f.offset, io.SeekStart)
+			if err != nil {
+				file.Close()
+				return 0, err
+			}
+		}
+		f.f = file
 	}
 	return f.f.Read(b)
 }
 
+// Duplicate returns n copies of this file, each with their own file handle
+// that continues from where this file has been read to.
+// The file handle of this file is closed.
+func (f *File) Duplicate(n int) ([]interface{}, error) {
+	var offset = f.offset
+	if f.f != nil {
+		if s, ok := f.f.(io.Seeker); ok {
+			var err error
+			offset, err = s.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err := f.f.Close()
+		f.f = nil
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var copies = make([]interface{}, n)
+	for i := range copies {
+		c := *f
+		c.offset = offset
+		copies[i] = &c
+	}
+	return copies, nil
+}
+
 func (f *File) Close() error {
 	if f.f == nil {
 		return nil
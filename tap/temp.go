@@ -7,7 +7,8 @@ import (
 )
 
 // MkTemp generates a temporary file containing x and returns the path for that file.
-func MkTemp(x interface{}) (string, error) {
+// The file is removed when scope exits, or when the program exits if scope is nil.
+func MkTemp(x interface{}, scope *Scope) (string, error) {
 	r, err := Reader(x)
 	if err != nil {
 		return "", err
@@ -32,9 +33,14 @@ func MkTemp(x interface{}) (string, error) {
 		return "", err
 	}
 
-	Defer(func() error {
-		return os.Remove(t.Name())
-	})
-
-	return t.Name(), nil
+	var path = t.Name()
+	remove := func() error {
+		return os.Remove(path)
+	}
+	if scope != nil {
+		scope.Defer(remove)
+	} else {
+		Defer(remove)
+	}
+	return path, nil
 }
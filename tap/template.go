@@ -2,25 +2,51 @@ package tap
 
 import (
 	"github.com/flosch/pongo2"
+	"sync"
 )
 
-var engine = pongo2.NewSet("pipe", pongo2.MustNewLocalFileSystemLoader(""))
+var (
+	engine = pongo2.NewSet("pipe", pongo2.MustNewLocalFileSystemLoader(""))
+	// compiling templates modifies the template set so must not be done concurrently
+	compile sync.Mutex
+)
 
 func init() {
 	pongo2.SetAutoescape(false)
 }
 
+// Global makes a variable available to all templates
+func Global(name string, value interface{}) {
+	compile.Lock()
+	engine.Globals[name] = value
+	compile.Unlock()
+}
+
 type Template string
 
 // Render the template.
+// If ctx contains a scope then temporary files created by the template are removed when the scope exits.
 func (t Template) Render(ctx pongo2.Context) (string, error) {
-	ts, err := engine.FromString(string(t))
+	scope, _ := ctx[ScopeKey].(*Scope)
+	ts, err := t.compile(scope)
 	if err != nil {
 		return "", err
 	}
 	return ts.Execute(ctx)
 }
 
+// compile compiles the template so that temporary files it creates are removed when scope exits.
+// Filters are bound to a template when it is compiled, so mktemp is replaced with one bound to scope only while compiling.
+func (t Template) compile(scope *Scope) (*pongo2.Template, error) {
+	compile.Lock()
+	defer compile.Unlock()
+	if scope != nil {
+		_ = pongo2.ReplaceFilter("mktemp", scopedTempFileFilter(scope))
+		defer pongo2.ReplaceFilter("mktemp", TempFileFilter)
+	}
+	return engine.FromString(string(t))
+}
+
 type TemplateSet []string
 
 func (s TemplateSet) Render(ctx pongo2.Context) ([]string, error) {
@@ -0,0 +1,213 @@
+package pipe
+
+import (
+	"context"
+	"github.com/relvacode/pipe/tap"
+	"github.com/sirupsen/logrus"
+	"io"
+	"sync"
+)
+
+// PassPipe writes every frame it reads unchanged.
+// Use it with a tag to name all of the objects in a stream.
+type PassPipe struct{}
+
+func (PassPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+
+		err = stream.With(f).Write(nil, f.Object)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// syncStream serialises writes from many goroutines to the same stream
+type syncStream struct {
+	Stream
+	mtx *sync.Mutex
+}
+
+func (s *syncStream) Write(cancel <-chan struct{}, obj interface{}) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.Stream.Write(cancel, obj)
+}
+
+func (s *syncStream) With(f *DataFrame) Stream {
+	return &syncStream{
+		Stream: s.Stream.With(f),
+		mtx:    s.mtx,
+	}
+}
+
+// branchStream reads frames from a channel and writes to the output of a branching pipe
+type branchStream struct {
+	ctx   context.Context
+	input <-chan *DataFrame
+	out   Stream
+}
+
+func (s *branchStream) Read(cancel <-chan struct{}) (*DataFrame, error) {
+	select {
+	case f, ok := <-s.input:
+		if !ok {
+			return nil, io.EOF
+		}
+		return f, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	case <-cancel:
+		return nil, ErrIOCancelled
+	}
+}
+
+func (s *branchStream) Write(cancel <-chan struct{}, obj interface{}) error {
+	return s.out.Write(cancel, obj)
+}
+
+func (s *branchStream) With(f *DataFrame) Stream {
+	return s.out.With(f)
+}
+
+// Branch is a sub-pipeline of a branching pipe.
+// All objects written by the branch are tagged with the name of the branch.
+type Branch struct {
+	Tag   *Tag
+	Pipes []Runnable
+}
+
+// SubPipe returns the sub-pipeline of this branch
+func (b Branch) SubPipe() SubPipe {
+	if b.Tag == nil {
+		return SubPipe(b.Pipes)
+	}
+	pipes := make([]Runnable, len(b.Pipes)+1)
+	copy(pipes, b.Pipes)
+	pipes[len(pipes)-1] = Runnable{
+		Pipe: PassPipe{},
+		Tag:  b.Tag,
+	}
+	return SubPipe(pipes)
+}
+
+type teeBranch struct {
+	input chan *DataFrame
+	done  chan struct{}
+}
+
+// TeePipe sends a copy of every input frame to each of its branches
+// and writes all objects produced by those branches.
+// Readers are duplicated so that each branch can read its copy independently.
+//
+// Each branch buffers up to Buffer frames.
+// If Drop then frames are dropped for any branch whose buffer is full,
+// otherwise the TeePipe waits for the slowest branch.
+type TeePipe struct {
+	Branches []Branch
+	Buffer   int
+	Drop     bool
+}
+
+func (p *TeePipe) send(ctx context.Context, b *teeBranch, f *DataFrame) error {
+	if p.Drop {
+		select {
+		case b.input <- f:
+		case <-b.done:
+			tap.Close(f.Object)
+		default:
+			logrus.Debugf("tee: dropped %s", f)
+			tap.Close(f.Object)
+		}
+		return nil
+	}
+
+	select {
+	case b.input <- f:
+		return nil
+	case <-b.done:
+		// The branch has stopped reading
+		tap.Close(f.Object)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *TeePipe) tee(ctx context.Context, stream Stream, branches []*teeBranch) error {
+	for {
+		f, err := stream.Read(ctx.Done())
+		if err != nil {
+			return err
+		}
+
+		copies, err := tap.Duplicate(f.Object, len(branches))
+		if err != nil {
+			return err
+		}
+
+		for i, b := range branches {
+			err = p.send(ctx, b, &DataFrame{
+				Tag:    f.Tag,
+				Object: copies[i],
+				Index:  f.Index,
+				Stack:  f.Stack,
+				scope:  f.scope,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *TeePipe) Go(ctx context.Context, stream Stream) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		out      = &syncStream{Stream: stream, mtx: new(sync.Mutex)}
+		branches = make([]*teeBranch, len(p.Branches))
+		errs     = make(chan error, len(p.Branches))
+	)
+
+	for i := range p.Branches {
+		b := &teeBranch{
+			input: make(chan *DataFrame, p.Buffer),
+			done:  make(chan struct{}),
+		}
+		branches[i] = b
+		go func(sub SubPipe) {
+			defer close(b.done)
+			err := sub.Go(ctx, &branchStream{
+				ctx:   ctx,
+				input: b.input,
+				out:   out,
+			})
+			if err != nil {
+				cancel()
+			}
+			errs <- err
+		}(p.Branches[i].SubPipe())
+	}
+
+	err := p.tee(ctx, stream, branches)
+	for _, b := range branches {
+		close(b.input)
+	}
+
+	var failed RuntimeError
+	for range branches {
+		if e := <-errs; e != nil {
+			failed = append(failed, e)
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return err
+}
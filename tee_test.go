@@ -0,0 +1,112 @@
+package pipe
+
+import (
+	"context"
+	"github.com/relvacode/pipe/tap"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// readAllPipe reads each input reader and writes its contents as a string
+type readAllPipe struct{}
+
+func (readAllPipe) Go(ctx context.Context, stream Stream) error {
+	for {
+		f, err := stream.Read(nil)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(f.Object.(io.Reader))
+		if err != nil {
+			return err
+		}
+		err = stream.Write(nil, string(b))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestTeePipe(t *testing.T) {
+	var (
+		source = sliceSourcePipe{strings.NewReader("a"), strings.NewReader("b")}
+		result = new(collectPipe)
+		tee    = &TeePipe{
+			Branches: []Branch{
+				{Tag: NewTag("x"), Pipes: []Runnable{{Pipe: readAllPipe{}}}},
+				{Tag: NewTag("y"), Pipes: []Runnable{{Pipe: readAllPipe{}}}},
+			},
+		}
+	)
+	err := Run(context.Background(), []Runnable{
+		{Pipe: source, Tag: NewTag("in")},
+		{Pipe: tee},
+		{Pipe: result},
+	}).ErrorOrNil()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for i, f := range result.Frames {
+		if f.Index != uint64(i) {
+			t.Fatalf("expected frame %d to have index %d but got %d", i, i, f.Index)
+		}
+		if _, ok := f.Stack["in"]; !ok {
+			t.Fatalf("expected frame %d to contain the input in its stack", i)
+		}
+		for _, tag := range []string{"x", "y"} {
+			if v, ok := f.Stack[tag]; ok {
+				got = append(got, tag+v.(string))
+			}
+		}
+	}
+	sort.Strings(got)
+
+	var want = []string{"xa", "xb", "ya", "yb"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v but got %v", want, got)
+	}
+}
+
+func TestTeePipe_File(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "pipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString("abcdef")
+	tmp.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := tap.OpenFile(tmp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b = make([]byte, 2)
+	if _, err = io.ReadFull(f, b); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each copy continues from where the file was read to
+	copies, err := tap.Duplicate(f, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range copies {
+		b, err := ioutil.ReadAll(c.(io.Reader))
+		tap.Close(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "cdef" {
+			t.Fatalf("expected %q but got %q", "cdef", b)
+		}
+	}
+}